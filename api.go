@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+)
+
+// api runs a headless HTTP API which manages sloppy sessions.
+func api(args []string) {
+	fs := flag.NewFlagSet("api", flag.ExitOnError)
+	setup := newSetup(fs, "deny")
+	var addr string
+	fs.StringVar(&addr, "addr", "localhost:8080", "address to listen on")
+	var token string
+	var sessionTTL time.Duration
+	fs.StringVar(&token, "token", os.Getenv("SLOPPY_API_TOKEN"), "bearer token required by the api (generated if empty)")
+	fs.DurationVar(&sessionTTL, "session-ttl", time.Hour, "delete sessions which have been idle for this long (0 to disable)")
+	fs.Parse(args)
+	ctx := context.Background()
+	servers := sloppy.NewServerManager()
+	defer servers.Close()
+	// the api is headless, so commands are shown on stderr and get no input
+	config, err := setup.Start(ctx, servers, builtinProviders(os.Stderr, nil), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if token == "" {
+		token = randomID(16)
+		log.Printf("API token: %s", token)
+	}
+	a := &apiServer{
+		servers:  servers,
+		hooks:    config.DriverHooks(),
+		newAgent: setup.NewAgent,
+		token:    token,
+		hosts:    apiHosts(addr),
+		sessions: map[string]*apiSession{},
+	}
+	if sessionTTL > 0 {
+		go a.expire(sessionTTL)
+	}
+	log.Printf("serving API at http://%s", addr)
+	if err := http.ListenAndServe(addr, a.Handler()); err != nil {
+		servers.Close()
+		log.Fatal(err)
+	}
+}
+
+// Events which are recorded by the api in addition to the driver's events.
+const (
+	// apiEventPrompt is a prompt posted to the session.
+	apiEventPrompt sloppy.EventType = "prompt"
+	// apiEventDone is the loop finishing, whether or not it succeeded.
+	apiEventDone sloppy.EventType = "done"
+)
+
+// apiEvent is an event with its position in the session history.
+type apiEvent struct {
+	ID int `json:"id"`
+	sloppy.Event
+}
+
+// apiHosts returns the Host header values accepted by an api listening on addr.
+// Restricting them prevents DNS rebinding attacks on local servers. It returns
+// nil when listening on all interfaces, where any host name may be valid.
+func apiHosts(addr string) []string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		return nil
+	}
+	return []string{"localhost", "127.0.0.1", "::1", strings.ToLower(host)}
+}
+
+// randomID returns n random bytes encoded as hex.
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+type apiSession struct {
+	id     string
+	driver sloppy.Driver
+	// done is closed when the session is deleted.
+	done chan struct{}
+
+	mu       sync.Mutex
+	events   []apiEvent
+	changed  chan struct{}
+	cancel   context.CancelFunc
+	streams  int
+	lastUsed time.Time
+}
+
+// Event records the event and wakes up any streams.
+func (s *apiSession) Event(e sloppy.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(e)
+}
+
+// record appends the event to the history.
+// The caller must hold the lock.
+func (s *apiSession) record(e sloppy.Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	s.events = append(s.events, apiEvent{ID: len(s.events) + 1, Event: e})
+	s.lastUsed = e.Time
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// since returns the events after the provided id and a channel which is
+// closed when more are added.
+func (s *apiSession) since(id int) ([]apiEvent, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if id < 0 || id > len(s.events) {
+		id = len(s.events)
+	}
+	return s.events[id:], s.changed
+}
+
+type apiSessionInfo struct {
+	ID      string `json:"id"`
+	Running bool   `json:"running"`
+	Events  int    `json:"events"`
+}
+
+func (s *apiSession) info() apiSessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return apiSessionInfo{
+		ID:      s.id,
+		Running: s.cancel != nil,
+		Events:  len(s.events),
+	}
+}
+
+// touch marks the session as used.
+func (s *apiSession) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed = time.Now()
+}
+
+// idle returns how long the session has been unused.
+// Sessions which are running or being streamed are never idle.
+func (s *apiSession) idle(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil || s.streams > 0 {
+		return 0
+	}
+	return now.Sub(s.lastUsed)
+}
+
+// stream registers an event stream and returns a func which unregisters it.
+func (s *apiSession) stream() func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streams++
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.streams--
+		s.lastUsed = time.Now()
+	}
+}
+
+// run starts the loop in the background.
+// It returns false if the session is already running.
+func (s *apiSession) run(prompt string) bool {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return false
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+	s.Event(sloppy.Event{Type: apiEventPrompt, Agent: "user", Text: prompt})
+	go func() {
+		// errors are recorded by the driver
+		s.driver.Loop(ctx, prompt)
+		cancel()
+		s.mu.Lock()
+		s.cancel = nil
+		s.record(sloppy.Event{Type: apiEventDone})
+		s.mu.Unlock()
+	}()
+	return true
+}
+
+// stop cancels the running loop.
+// It returns false if the session isn't running.
+func (s *apiSession) stop() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel == nil {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+type apiServer struct {
+	servers *sloppy.ServerManager
+	hooks   []sloppy.Hook
+	// newAgent creates the agents used by the sessions.
+	newAgent func(name string) sloppy.Agent
+	// token is the bearer token required by every request.
+	// It is not checked when empty.
+	token string
+	// hosts are the allowed Host header values.
+	// Any host is allowed when nil.
+	hosts []string
+
+	mu       sync.Mutex
+	sessions map[string]*apiSession
+}
+
+// Handler returns the API's http handler.
+func (a *apiServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sessions", a.createSession)
+	mux.HandleFunc("GET /sessions", a.listSessions)
+	mux.HandleFunc("GET /sessions/{id}", a.getSession)
+	mux.HandleFunc("DELETE /sessions/{id}", a.deleteSession)
+	mux.HandleFunc("POST /sessions/{id}/prompts", a.postPrompt)
+	mux.HandleFunc("POST /sessions/{id}/cancel", a.cancelSession)
+	mux.HandleFunc("GET /sessions/{id}/history", a.getHistory)
+	mux.HandleFunc("GET /sessions/{id}/events", a.streamEvents)
+	return a.authorize(mux)
+}
+
+// authorize rejects requests with an unknown Host header or without the token.
+func (a *apiServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.allowedHost(r.Host) {
+			http.Error(w, "invalid host", http.StatusForbidden)
+			return
+		}
+		if a.token != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *apiServer) allowedHost(host string) bool {
+	if a.hosts == nil {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	return slices.Contains(a.hosts, strings.ToLower(host))
+}
+
+// expire periodically deletes sessions which have been idle for longer than ttl.
+func (a *apiServer) expire(ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, time.Minute))
+	defer ticker.Stop()
+	for now := range ticker.C {
+		a.evict(now, ttl)
+	}
+}
+
+// evict deletes the sessions which have been idle for longer than ttl.
+func (a *apiServer) evict(now time.Time, ttl time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for id, s := range a.sessions {
+		if s.idle(now) > ttl {
+			delete(a.sessions, id)
+			close(s.done)
+		}
+	}
+}
+
+func (a *apiServer) session(w http.ResponseWriter, r *http.Request) (*apiSession, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	s, ok := a.sessions[r.PathValue("id")]
+	if !ok {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return nil, false
+	}
+	s.touch()
+	return s, true
+}
+
+func (a *apiServer) createSession(w http.ResponseWriter, r *http.Request) {
+	s := &apiSession{
+		id:       randomID(8),
+		done:     make(chan struct{}),
+		changed:  make(chan struct{}),
+		lastUsed: time.Now(),
+	}
+	s.driver = sloppy.Driver{
+		Servers:  a.servers,
+		Sink:     s,
+		Hooks:    a.hooks,
+		NewAgent: a.newAgent,
+	}
+	a.mu.Lock()
+	a.sessions[s.id] = s
+	a.mu.Unlock()
+	writeJSON(w, http.StatusCreated, s.info())
+}
+
+func (a *apiServer) listSessions(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	infos := []apiSessionInfo{}
+	for _, s := range a.sessions {
+		infos = append(infos, s.info())
+	}
+	a.mu.Unlock()
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (a *apiServer) getSession(w http.ResponseWriter, r *http.Request) {
+	if s, ok := a.session(w, r); ok {
+		writeJSON(w, http.StatusOK, s.info())
+	}
+}
+
+func (a *apiServer) deleteSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.session(w, r)
+	if !ok {
+		return
+	}
+	s.stop()
+	a.mu.Lock()
+	if a.sessions[s.id] == s {
+		delete(a.sessions, s.id)
+		close(s.done)
+	}
+	a.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiServer) postPrompt(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.session(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if body.Prompt == "" {
+		http.Error(w, "prompt is required", http.StatusBadRequest)
+		return
+	}
+	if !s.run(body.Prompt) {
+		http.Error(w, "session is already running", http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, s.info())
+}
+
+func (a *apiServer) cancelSession(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.session(w, r)
+	if !ok {
+		return
+	}
+	if !s.stop() {
+		http.Error(w, "session is not running", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *apiServer) getHistory(w http.ResponseWriter, r *http.Request) {
+	if s, ok := a.session(w, r); ok {
+		events, _ := s.since(0)
+		writeJSON(w, http.StatusOK, events)
+	}
+}
+
+// streamEvents streams the session's events using server-sent events.
+// All events are replayed unless the Last-Event-ID header is set, in which
+// case only the events after it are sent.
+func (a *apiServer) streamEvents(w http.ResponseWriter, r *http.Request) {
+	s, ok := a.session(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	var last int
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		last = n
+	}
+	defer s.stream()()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		events, changed := s.since(last)
+		for _, e := range events {
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("ERROR: failed to encode event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+			last = e.ID
+		}
+		flusher.Flush()
+		select {
+		case <-changed:
+		case <-s.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
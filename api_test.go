@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+)
+
+// newTestAPI returns an api server without any mcp servers.
+func newTestAPI(t *testing.T) *apiServer {
+	t.Helper()
+	servers := sloppy.NewServerManager()
+	t.Cleanup(func() { servers.Close() })
+	return &apiServer{
+		servers:  servers,
+		newAgent: (&setup{model: sloppy.DefaultModel}).NewAgent,
+		token:    "secret",
+		hosts:    apiHosts("localhost:8080"),
+		sessions: map[string]*apiSession{},
+	}
+}
+
+func TestAPIHosts(t *testing.T) {
+	tests := []struct {
+		addr  string
+		hosts []string
+	}{
+		{addr: "localhost:8080", hosts: []string{"localhost", "127.0.0.1", "::1", "localhost"}},
+		{addr: "Example.com:80", hosts: []string{"localhost", "127.0.0.1", "::1", "example.com"}},
+		{addr: "[::1]:8080", hosts: []string{"localhost", "127.0.0.1", "::1", "::1"}},
+		{addr: ":8080", hosts: nil},
+		{addr: "0.0.0.0:8080", hosts: nil},
+		{addr: "[::]:8080", hosts: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := apiHosts(tt.addr); !slices.Equal(got, tt.hosts) {
+				t.Fatalf("got %v, want %v", got, tt.hosts)
+			}
+		})
+	}
+}
+
+func TestAPIAuthorize(t *testing.T) {
+	h := newTestAPI(t).Handler()
+	tests := []struct {
+		name   string
+		host   string
+		auth   string
+		status int
+	}{
+		{name: "valid", host: "localhost:8080", auth: "Bearer secret", status: http.StatusOK},
+		{name: "loopback ip", host: "127.0.0.1:8080", auth: "Bearer secret", status: http.StatusOK},
+		{name: "ipv6 loopback", host: "[::1]:8080", auth: "Bearer secret", status: http.StatusOK},
+		{name: "no port", host: "localhost", auth: "Bearer secret", status: http.StatusOK},
+		{name: "rebound host", host: "evil.example.com:8080", auth: "Bearer secret", status: http.StatusForbidden},
+		{name: "missing token", host: "localhost:8080", auth: "", status: http.StatusUnauthorized},
+		{name: "wrong token", host: "localhost:8080", auth: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "wrong scheme", host: "localhost:8080", auth: "Basic secret", status: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/sessions", nil)
+			req.Host = tt.host
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("got status %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAPIEvict(t *testing.T) {
+	a := newTestAPI(t)
+	h := a.Handler()
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Host = "localhost:8080"
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	create := func() string {
+		rec := do(http.MethodPost, "/sessions")
+		var info apiSessionInfo
+		if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+			t.Fatal(err)
+		}
+		return info.ID
+	}
+	idle, running, streamed := create(), create(), create()
+	a.mu.Lock()
+	a.sessions[running].cancel = func() {}
+	a.sessions[streamed].streams = 1
+	a.mu.Unlock()
+
+	now := time.Now()
+	a.evict(now, time.Hour)
+	if rec := do(http.MethodGet, "/sessions/"+idle); rec.Code != http.StatusOK {
+		t.Fatalf("recently used session was evicted: %d", rec.Code)
+	}
+
+	a.mu.Lock()
+	done := a.sessions[idle].done
+	a.mu.Unlock()
+	a.evict(now.Add(2*time.Hour), time.Hour)
+	select {
+	case <-done:
+	default:
+		t.Fatal("evicted session wasn't closed")
+	}
+	tests := []struct {
+		id     string
+		status int
+	}{
+		{id: idle, status: http.StatusNotFound},
+		{id: running, status: http.StatusOK},
+		{id: streamed, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := do(http.MethodGet, "/sessions/"+tt.id); rec.Code != tt.status {
+			t.Fatalf("session %s: got status %d, want %d", tt.id, rec.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/icholy/sloppy/internal/readline"
+)
+
+// Command is a REPL slash command.
+type Command struct {
+	Name string
+	// Usage describes the arguments, for example "<server>".
+	Usage string
+	Help  string
+	// MinArgs and MaxArgs bound the number of arguments.
+	// There is no upper bound when MaxArgs is negative.
+	MinArgs int
+	MaxArgs int
+	Run     func(ctx context.Context, args []string) error
+	// Complete returns the candidates for the last argument.
+	Complete func(args []string) []string
+}
+
+// Commands is a registry of slash commands.
+type Commands struct {
+	commands map[string]*Command
+}
+
+// Add registers the command. It returns an error if a command
+// with the same name already exists.
+func (c *Commands) Add(cmd *Command) error {
+	if c.commands == nil {
+		c.commands = map[string]*Command{}
+	}
+	if _, ok := c.commands[cmd.Name]; ok {
+		return fmt.Errorf("duplicate command: /%s", cmd.Name)
+	}
+	c.commands[cmd.Name] = cmd
+	return nil
+}
+
+// Lookup returns the named command.
+func (c *Commands) Lookup(name string) (*Command, bool) {
+	cmd, ok := c.commands[name]
+	return cmd, ok
+}
+
+// List returns the commands sorted by name.
+func (c *Commands) List() []*Command {
+	var cmds []*Command
+	for _, cmd := range c.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// Run parses and runs a "/name args..." command line.
+func (c *Commands) Run(ctx context.Context, line string) error {
+	name, rest, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(line), "/"), " ")
+	cmd, ok := c.Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown command: /%s (see /help)", name)
+	}
+	args, err := splitArgs(rest)
+	if err != nil {
+		return err
+	}
+	if len(args) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(args) > cmd.MaxArgs) {
+		return fmt.Errorf("usage: %s", cmd.Synopsis())
+	}
+	return cmd.Run(ctx, args)
+}
+
+// Complete returns the completion candidates for the last word of a
+// command line.
+func (c *Commands) Complete(line string) []string {
+	name, rest, ok := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	if !ok {
+		var names []string
+		for _, cmd := range c.commands {
+			names = append(names, "/"+cmd.Name)
+		}
+		return readline.CompleteWords("/"+name, names)
+	}
+	cmd, ok := c.Lookup(name)
+	if !ok || cmd.Complete == nil {
+		return nil
+	}
+	args := strings.Fields(rest)
+	if rest == "" || strings.HasSuffix(rest, " ") {
+		args = append(args, "")
+	}
+	return cmd.Complete(args)
+}
+
+// Synopsis returns the command name followed by its usage.
+func (cmd *Command) Synopsis() string {
+	if cmd.Usage == "" {
+		return "/" + cmd.Name
+	}
+	return "/" + cmd.Name + " " + cmd.Usage
+}
+
+// TemplateCommand is a user-defined command loaded from a markdown file.
+// Running it sends the file contents as a prompt, with $ARGUMENTS replaced
+// by the command's arguments. The help text is taken from a description in
+// the file's front matter, or its first line.
+type TemplateCommand struct {
+	Name        string
+	Description string
+	Template    string
+}
+
+// Expand returns the prompt for the arguments.
+func (t *TemplateCommand) Expand(args []string) string {
+	return strings.ReplaceAll(t.Template, "$ARGUMENTS", strings.Join(args, " "))
+}
+
+// LoadTemplateCommands loads the *.md files in the directories. The command
+// name is the file name without the extension. When multiple directories
+// contain the same command, the first one wins. Directories which don't
+// exist are ignored.
+func LoadTemplateCommands(dirs ...string) ([]*TemplateCommand, error) {
+	var templates []*TemplateCommand
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		names, err := filepath.Glob(filepath.Join(dir, "*.md"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			cmd := strings.TrimSuffix(filepath.Base(name), ".md")
+			if seen[cmd] {
+				continue
+			}
+			seen[cmd] = true
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read command: %w", err)
+			}
+			description, template := parseFrontMatter(string(data))
+			if description == "" {
+				description, _, _ = strings.Cut(strings.TrimSpace(template), "\n")
+			}
+			templates = append(templates, &TemplateCommand{
+				Name:        cmd,
+				Description: truncate(strings.TrimLeft(description, "# "), 80),
+				Template:    template,
+			})
+		}
+	}
+	return templates, nil
+}
+
+// parseFrontMatter returns the description from the front matter and the
+// content after it. Other front matter fields are ignored.
+func parseFrontMatter(s string) (description, content string) {
+	rest, ok := strings.CutPrefix(s, "---\n")
+	if !ok {
+		return "", s
+	}
+	front, content, ok := strings.Cut(rest, "\n---\n")
+	if !ok {
+		return "", s
+	}
+	for _, line := range strings.Split(front, "\n") {
+		if v, ok := strings.CutPrefix(line, "description:"); ok {
+			description = strings.Trim(strings.TrimSpace(v), `"'`)
+		}
+	}
+	return description, content
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		input string
+		args  []string
+		err   bool
+	}{
+		{input: "", args: nil},
+		{input: "a b  c", args: []string{"a", "b", "c"}},
+		{input: "\ta\tb ", args: []string{"a", "b"}},
+		{input: `"hello world" x`, args: []string{"hello world", "x"}},
+		{input: `a"b c"d`, args: []string{"ab cd"}},
+		{input: `""`, args: []string{""}},
+		{input: `"unterminated`, err: true},
+	}
+	for _, tt := range tests {
+		args, err := splitArgs(tt.input)
+		if (err != nil) != tt.err {
+			t.Fatalf("splitArgs(%q): unexpected error: %v", tt.input, err)
+		}
+		if !slices.Equal(args, tt.args) {
+			t.Fatalf("splitArgs(%q) = %q, want %q", tt.input, args, tt.args)
+		}
+	}
+}
+
+func TestCommandsRun(t *testing.T) {
+	var got []string
+	var commands Commands
+	for _, cmd := range []*Command{
+		{Name: "none", MaxArgs: 0},
+		{Name: "one", Usage: "<name>", MinArgs: 1, MaxArgs: 1},
+		{Name: "many", MaxArgs: -1},
+	} {
+		cmd.Run = func(ctx context.Context, args []string) error {
+			got = args
+			return nil
+		}
+		if err := commands.Add(cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := commands.Add(&Command{Name: "one"}); err == nil {
+		t.Fatal("expected an error for a duplicate command")
+	}
+	tests := []struct {
+		line string
+		args []string
+		err  string
+	}{
+		{line: "/none", args: nil},
+		{line: "  /none  ", args: nil},
+		{line: "/none x", err: "usage: /none"},
+		{line: "/one", err: "usage: /one <name>"},
+		{line: "/one a b", err: "usage: /one <name>"},
+		{line: `/one "a b"`, args: []string{"a b"}},
+		{line: `/many a "b c" d`, args: []string{"a", "b c", "d"}},
+		{line: `/many "a`, err: "unterminated quote"},
+		{line: "/missing", err: "unknown command: /missing"},
+	}
+	for _, tt := range tests {
+		got = nil
+		err := commands.Run(context.Background(), tt.line)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("%q: got error %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", tt.line, err)
+		}
+		if !slices.Equal(got, tt.args) {
+			t.Fatalf("%q: got args %q, want %q", tt.line, got, tt.args)
+		}
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		description string
+		content     string
+	}{
+		{
+			name:    "none",
+			input:   "Review the code\n",
+			content: "Review the code\n",
+		},
+		{
+			name:        "description",
+			input:       "---\ndescription: Review a PR\nmodel: opus\n---\nReview $ARGUMENTS\n",
+			description: "Review a PR",
+			content:     "Review $ARGUMENTS\n",
+		},
+		{
+			name:        "quoted",
+			input:       "---\ndescription: \"Review: a PR\"\n---\nbody",
+			description: "Review: a PR",
+			content:     "body",
+		},
+		{
+			name:    "no description",
+			input:   "---\nmodel: opus\n---\nbody",
+			content: "body",
+		},
+		{
+			name:    "unterminated",
+			input:   "---\ndescription: x\nbody",
+			content: "---\ndescription: x\nbody",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			description, content := parseFrontMatter(tt.input)
+			if description != tt.description {
+				t.Fatalf("got description %q, want %q", description, tt.description)
+			}
+			if content != tt.content {
+				t.Fatalf("got content %q, want %q", content, tt.content)
+			}
+		})
+	}
+}
+
+func TestLoadTemplateCommands(t *testing.T) {
+	project := t.TempDir()
+	home := t.TempDir()
+	writeCommand := func(dir, name, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCommand(project, "review.md", "---\ndescription: Review the project\n---\nReview $ARGUMENTS carefully\n")
+	writeCommand(home, "review.md", "Review from home\n")
+	writeCommand(home, "explain.md", "\n# Explain the code\n\nExplain $ARGUMENTS\n")
+	writeCommand(home, "notes.txt", "not a command")
+	templates, err := LoadTemplateCommands(project, home, filepath.Join(home, "missing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]*TemplateCommand{}
+	for _, tmpl := range templates {
+		got[tmpl.Name] = tmpl
+	}
+	if len(got) != 2 || got["review"] == nil || got["explain"] == nil {
+		t.Fatalf("got templates %v", got)
+	}
+	// the project directory takes precedence over the home directory
+	review := got["review"]
+	if review.Description != "Review the project" {
+		t.Fatalf("got description %q", review.Description)
+	}
+	if want := "Review main.go util.go carefully\n"; review.Expand([]string{"main.go", "util.go"}) != want {
+		t.Fatalf("got prompt %q, want %q", review.Expand([]string{"main.go", "util.go"}), want)
+	}
+	// without front matter, the description is the first line
+	explain := got["explain"]
+	if explain.Description != "Explain the code" {
+		t.Fatalf("got description %q", explain.Description)
+	}
+	if want := "\n# Explain the code\n\nExplain \n"; explain.Expand(nil) != want {
+		t.Fatalf("got prompt %q, want %q", explain.Expand(nil), want)
+	}
+}
@@ -3,21 +3,179 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"maps"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/icholy/sloppy/internal/builtin"
+	"github.com/icholy/sloppy/internal/mcpx"
 	"github.com/icholy/sloppy/internal/sloppy"
 	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 type MCPServerConfig struct {
-	Command string   `json:"command"`
-	Args    []string `json:"args"`
+	Command   string            `json:"command"`
+	Args      []string          `json:"args"`
+	Env       map[string]string `json:"env"`
+	EnvFile   string            `json:"envFile"`
+	Cwd       string            `json:"cwd"`
+	URL       string            `json:"url"`
+	Transport string            `json:"transport"`
+	Headers   map[string]string `json:"headers"`
+	Required  bool              `json:"required"`
+	Timeout   float64           `json:"timeout"`
+
+	IncludeTools     []string          `json:"includeTools"`
+	ExcludeTools     []string          `json:"excludeTools"`
+	ToolAliases      map[string]string `json:"toolAliases"`
+	ToolDescriptions map[string]string `json:"toolDescriptions"`
+}
+
+// ToolFilter returns the filter for the server's tools.
+func (s *MCPServerConfig) ToolFilter() *sloppy.ToolFilter {
+	return &sloppy.ToolFilter{
+		Include:      s.IncludeTools,
+		Exclude:      s.ExcludeTools,
+		Aliases:      s.ToolAliases,
+		Descriptions: s.ToolDescriptions,
+	}
+}
+
+// NewClient creates and starts an MCP client for the server.
+// Servers with a url use the sse or streamable http transport,
+// all others are launched as a stdio subprocess. Sampling requests
+// are only supported by stdio servers.
+func (s *MCPServerConfig) NewClient(ctx context.Context, sampling mcpx.SamplingHandler) (*client.Client, error) {
+	if s.URL == "" {
+		if s.Command == "" {
+			return nil, fmt.Errorf("either command or url is required")
+		}
+		env, err := s.Environ()
+		if err != nil {
+			return nil, err
+		}
+		cmd := exec.Command(s.Command, s.Args...)
+		cmd.Env = env
+		cmd.Dir = s.Cwd
+		return mcpx.NewCommandClient(ctx, cmd, sampling)
+	}
+	var c *client.Client
+	var err error
+	switch s.Transport {
+	case "sse":
+		c, err = client.NewSSEMCPClient(s.URL, client.WithHeaders(s.Headers))
+	case "", "http", "streamable-http":
+		c, err = client.NewStreamableHttpClient(s.URL, transport.WithHTTPHeaders(s.Headers))
+	default:
+		return nil, fmt.Errorf("unsupported transport: %q", s.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// the sse transport keeps using the context after Start returns
+	if err := c.Start(context.WithoutCancel(ctx)); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Environ returns the environment for a stdio server. The parent environment
+// is extended with the variables from the envFile followed by the env map.
+// Values may reference the parent environment using ${VAR}.
+func (s *MCPServerConfig) Environ() ([]string, error) {
+	env := os.Environ()
+	if s.EnvFile != "" {
+		vars, err := ReadEnvFile(s.EnvFile)
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, vars...)
+	}
+	keys := slices.Sorted(maps.Keys(s.Env))
+	for _, k := range keys {
+		env = append(env, k+"="+os.ExpandEnv(s.Env[k]))
+	}
+	return env, nil
+}
+
+// ReadEnvFile reads KEY=VALUE pairs from a dotenv style file.
+// Blank lines, comments, and the export keyword are ignored,
+// and values may be wrapped in single or double quotes.
+func ReadEnvFile(name string) ([]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file: %w", err)
+	}
+	var env []string
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid env file: %s:%d: missing '='", name, i+1)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		} else {
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = value[1 : len(value)-1]
+			}
+			value = os.ExpandEnv(value)
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
+
+// DefaultServerTimeout is used when a server doesn't specify a timeout.
+const DefaultServerTimeout = 30 * time.Second
+
+// HookConfig is a shell command which runs on a hook event.
+type HookConfig struct {
+	Matcher string  `json:"matcher"`
+	Command string  `json:"command"`
+	Timeout float64 `json:"timeout"`
+}
+
+// HooksConfig lists the hooks for each event.
+type HooksConfig struct {
+	PreToolCall      []HookConfig `json:"preToolCall"`
+	PostToolCall     []HookConfig `json:"postToolCall"`
+	UserPromptSubmit []HookConfig `json:"userPromptSubmit"`
+	TurnEnd          []HookConfig `json:"turnEnd"`
+}
+
+// ToolConfig is a custom tool which runs a command template.
+type ToolConfig struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Parameters  mcp.ToolInputSchema `json:"parameters"`
+	Command     []string            `json:"command"`
+	Cwd         string              `json:"cwd"`
+	Timeout     float64             `json:"timeout"`
 }
 
 type Config struct {
 	MCPServers map[string]*MCPServerConfig `json:"mcpServers"`
+	Hooks      HooksConfig                 `json:"hooks"`
+	Tools      []*ToolConfig               `json:"tools"`
+
+	// Sampling handles sampling requests from the servers.
+	Sampling func(ctx context.Context, server string, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) `json:"-"`
 }
 
 func ReadConfig(name string) (*Config, error) {
@@ -29,24 +187,135 @@ func ReadConfig(name string) (*Config, error) {
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, fmt.Errorf("failed to read config: %s: %w", name, err)
 	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %s: %w", name, err)
+	}
+	config.resolvePaths(filepath.Dir(name))
 	return &config, nil
 }
 
-func (c *Config) ListTools(ctx context.Context) ([]sloppy.Tool, error) {
-	var tools []sloppy.Tool
-	for name, opts := range c.MCPServers {
-		c, err := client.NewStdioMCPClient(opts.Command, nil, opts.Args...)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create mcp client: %s: %w", name, err)
+// resolvePaths makes the relative paths in the config relative
+// to dir, the directory which contains the config file.
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+	for _, s := range c.MCPServers {
+		s.Cwd = resolve(s.Cwd)
+		s.EnvFile = resolve(s.EnvFile)
+	}
+	for _, t := range c.Tools {
+		t.Cwd = resolve(t.Cwd)
+	}
+}
+
+func (c *Config) validate() error {
+	for _, h := range c.DriverHooks() {
+		if h.Command == "" {
+			return fmt.Errorf("invalid %s hook: command is required", h.Event)
+		}
+	}
+	aliases := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(c.MCPServers)) {
+		s := c.MCPServers[name]
+		if err := s.ToolFilter().Validate(); err != nil {
+			return fmt.Errorf("invalid server: %s: %w", name, err)
 		}
-		if _, err := c.Initialize(ctx, mcp.InitializeRequest{}); err != nil {
-			return nil, fmt.Errorf("failed to initialize mcp client: %s: %w", name, err)
+		for _, alias := range slices.Sorted(maps.Values(s.ToolAliases)) {
+			if other, ok := aliases[alias]; ok {
+				return fmt.Errorf("servers %s and %s have the same tool alias: %q", other, name, alias)
+			}
+			aliases[alias] = name
 		}
-		tt, err := sloppy.ListClientTools(ctx, name, c)
-		if err != nil {
-			return nil, fmt.Errorf("failed to list mcp tools: %s: %w", name, err)
+	}
+	seen := map[string]bool{}
+	for _, t := range c.CommandTools() {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("duplicate tool: %s", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return nil
+}
+
+// CommandTools returns the custom tools.
+func (c *Config) CommandTools() []*builtin.CommandTool {
+	var tools []*builtin.CommandTool
+	for _, t := range c.Tools {
+		tools = append(tools, &builtin.CommandTool{
+			Name:        t.Name,
+			Description: t.Description,
+			Schema:      t.Parameters,
+			Command:     t.Command,
+			Dir:         t.Cwd,
+			Timeout:     time.Duration(t.Timeout * float64(time.Second)),
+		})
+	}
+	return tools
+}
+
+// DriverHooks returns the configured hooks in the order they run.
+func (c *Config) DriverHooks() []sloppy.Hook {
+	var hooks []sloppy.Hook
+	add := func(event sloppy.HookEvent, configs []HookConfig) {
+		for _, h := range configs {
+			hooks = append(hooks, sloppy.Hook{
+				Event:   event,
+				Matcher: h.Matcher,
+				Command: h.Command,
+				Timeout: time.Duration(h.Timeout * float64(time.Second)),
+			})
+		}
+	}
+	add(sloppy.HookPreToolCall, c.Hooks.PreToolCall)
+	add(sloppy.HookPostToolCall, c.Hooks.PostToolCall)
+	add(sloppy.HookUserPromptSubmit, c.Hooks.UserPromptSubmit)
+	add(sloppy.HookTurnEnd, c.Hooks.TurnEnd)
+	return hooks
+}
+
+// AddServers starts every configured server concurrently. Servers which
+// fail to start are reported as warnings unless they are required.
+func (c *Config) AddServers(ctx context.Context, m *sloppy.ServerManager) error {
+	var servers []sloppy.Server
+	names := slices.Sorted(maps.Keys(c.MCPServers))
+	for _, name := range names {
+		opts := c.MCPServers[name]
+		timeout := DefaultServerTimeout
+		if opts.Timeout > 0 {
+			timeout = time.Duration(opts.Timeout * float64(time.Second))
+		}
+		servers = append(servers, sloppy.Server{
+			Name: name,
+			Connect: func(ctx context.Context) (*client.Client, error) {
+				var sampling mcpx.SamplingHandler
+				if c.Sampling != nil {
+					sampling = func(ctx context.Context, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+						return c.Sampling(ctx, name, req)
+					}
+				}
+				return opts.NewClient(ctx, sampling)
+			},
+			Timeout: timeout,
+			Filter:  opts.ToolFilter(),
+		})
+	}
+	var required []error
+	for i, err := range m.Start(ctx, servers...) {
+		if err == nil {
+			continue
+		}
+		if c.MCPServers[names[i]].Required {
+			required = append(required, err)
+		} else {
+			log.Printf("WARNING: %s", err)
 		}
-		tools = append(tools, tt...)
 	}
-	return tools, nil
+	return errors.Join(required...)
 }
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestMCPServer returns a server with an echo tool.
+func newTestMCPServer() *server.MCPServer {
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text", mcp.Required())),
+		func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			text, _ := req.Params.Arguments["text"].(string)
+			return mcp.NewToolResultText(text), nil
+		})
+	return s
+}
+
+// streamableHandler is a minimal streamable http server
+// which answers every request with a single json response.
+func streamableHandler(s *server.MCPServer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := s.HandleMessage(r.Context(), data)
+		if res == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+// headerRecorder records the values of a request header.
+type headerRecorder struct {
+	name   string
+	mu     sync.Mutex
+	values []string
+}
+
+func (h *headerRecorder) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		h.values = append(h.values, r.Header.Get(h.name))
+		h.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *headerRecorder) all(value string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.values) > 0 && !slices.ContainsFunc(h.values, func(v string) bool { return v != value })
+}
+
+func TestMCPServerConfigNewClient(t *testing.T) {
+	tests := []struct {
+		transport string
+		handler   func(s *server.MCPServer, url string) http.Handler
+	}{
+		{
+			transport: "sse",
+			handler: func(s *server.MCPServer, url string) http.Handler {
+				return server.NewSSEServer(s, server.WithBaseURL(url))
+			},
+		},
+		{
+			transport: "",
+			handler: func(s *server.MCPServer, url string) http.Handler {
+				return streamableHandler(s)
+			},
+		},
+		{
+			transport: "http",
+			handler: func(s *server.MCPServer, url string) http.Handler {
+				return streamableHandler(s)
+			},
+		},
+		{
+			transport: "streamable-http",
+			handler: func(s *server.MCPServer, url string) http.Handler {
+				return streamableHandler(s)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run("transport="+tt.transport, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(nil)
+			ts.Start()
+			defer ts.Close()
+			auth := &headerRecorder{name: "Authorization"}
+			ts.Config.Handler = auth.wrap(tt.handler(newTestMCPServer(), ts.URL))
+			url := ts.URL
+			if tt.transport == "sse" {
+				url += "/sse"
+			}
+			config := &MCPServerConfig{
+				URL:       url,
+				Transport: tt.transport,
+				Headers:   map[string]string{"Authorization": "Bearer secret"},
+			}
+			ctx := context.Background()
+			c, err := config.NewClient(ctx, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			if _, err := c.Initialize(ctx, mcp.InitializeRequest{}); err != nil {
+				t.Fatal(err)
+			}
+			tools, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(tools.Tools) != 1 || tools.Tools[0].Name != "echo" {
+				t.Fatalf("unexpected tools: %v", tools.Tools)
+			}
+			var req mcp.CallToolRequest
+			req.Params.Name = "echo"
+			req.Params.Arguments = map[string]any{"text": "hello"}
+			res, err := c.CallTool(ctx, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Content) != 1 || res.Content[0].(mcp.TextContent).Text != "hello" {
+				t.Fatalf("unexpected result: %v", res.Content)
+			}
+			if !auth.all("Bearer secret") {
+				t.Fatalf("headers weren't sent with every request: %q", auth.values)
+			}
+		})
+	}
+}
+
+func TestMCPServerConfigNewClientErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config MCPServerConfig
+		err    string
+	}{
+		{
+			name:   "no command or url",
+			config: MCPServerConfig{},
+			err:    "either command or url is required",
+		},
+		{
+			name:   "unsupported transport",
+			config: MCPServerConfig{URL: "http://127.0.0.1:1", Transport: "websocket"},
+			err:    `unsupported transport: "websocket"`,
+		},
+		{
+			name:   "missing command",
+			config: MCPServerConfig{Command: filepath.Join(t.TempDir(), "missing")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.config.NewClient(context.Background(), nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.err != "" && err.Error() != tt.err {
+				t.Fatalf("got error %q, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadConfigResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "sloppy.json")
+	data := `{
+		"mcpServers": {
+			"relative": {"command": "server", "cwd": "work", "envFile": ".env"},
+			"absolute": {"command": "server", "cwd": "/srv", "envFile": "/etc/server.env"},
+			"default": {"command": "server"}
+		},
+		"tools": [
+			{"name": "relative", "command": ["make"], "cwd": "src"},
+			{"name": "absolute", "command": ["make"], "cwd": "/src"},
+			{"name": "default", "command": ["make"]}
+		]
+	}`
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfig(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := map[string]string{}
+	for _, tool := range config.Tools {
+		tools[tool.Name] = tool.Cwd
+	}
+	tests := []struct {
+		server  string
+		cwd     string
+		envFile string
+		toolCwd string
+	}{
+		{server: "relative", cwd: filepath.Join(dir, "work"), envFile: filepath.Join(dir, ".env"), toolCwd: filepath.Join(dir, "src")},
+		{server: "absolute", cwd: "/srv", envFile: "/etc/server.env", toolCwd: "/src"},
+		{server: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			s := config.MCPServers[tt.server]
+			if s.Cwd != tt.cwd {
+				t.Errorf("got cwd %q, want %q", s.Cwd, tt.cwd)
+			}
+			if s.EnvFile != tt.envFile {
+				t.Errorf("got envFile %q, want %q", s.EnvFile, tt.envFile)
+			}
+			if cwd := tools[tt.server]; cwd != tt.toolCwd {
+				t.Errorf("got tool cwd %q, want %q", cwd, tt.toolCwd)
+			}
+		})
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	t.Setenv("SLOPPY_TEST_HOME", "/home/test")
+	tests := []struct {
+		name string
+		data string
+		env  []string
+		err  bool
+	}{
+		{name: "empty", data: ""},
+		{name: "comments", data: "# comment\n\nA=1\n", env: []string{"A=1"}},
+		{name: "export", data: "export A=1", env: []string{"A=1"}},
+		{name: "double quotes", data: `A="x y"`, env: []string{"A=x y"}},
+		{name: "single quotes", data: `A='$SLOPPY_TEST_HOME'`, env: []string{"A=$SLOPPY_TEST_HOME"}},
+		{name: "expand", data: "A=${SLOPPY_TEST_HOME}/bin", env: []string{"A=/home/test/bin"}},
+		{name: "missing equals", data: "A", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), ".env")
+			if err := os.WriteFile(name, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			env, err := ReadEnvFile(name)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(env, tt.env) {
+				t.Fatalf("got %q, want %q", env, tt.env)
+			}
+		})
+	}
+}
+
+func TestReadConfigToolAliases(t *testing.T) {
+	tests := []struct {
+		name    string
+		servers string
+		err     string
+	}{
+		{
+			name:    "valid",
+			servers: `{"a": {"command": "a", "toolAliases": {"get_me": "whoami"}}}`,
+		},
+		{
+			name:    "invalid name",
+			servers: `{"a": {"command": "a", "toolAliases": {"get_me": "who.am.i"}}}`,
+			err:     "invalid tool name",
+		},
+		{
+			name:    "reserved name",
+			servers: `{"a": {"command": "a", "toolAliases": {"get_me": "read_resource"}}}`,
+			err:     "reserved",
+		},
+		{
+			name:    "duplicate in server",
+			servers: `{"a": {"command": "a", "toolAliases": {"get_me": "me", "whoami": "me"}}}`,
+			err:     "same alias",
+		},
+		{
+			name: "duplicate across servers",
+			servers: `{
+				"a": {"command": "a", "toolAliases": {"get_me": "me"}},
+				"b": {"command": "b", "toolAliases": {"whoami": "me"}}
+			}`,
+			err: "same tool alias",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "sloppy.json")
+			data := `{"mcpServers": ` + tt.servers + `}`
+			if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadConfig(name)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestConfigAddServers(t *testing.T) {
+	ts := httptest.NewServer(streamableHandler(newTestMCPServer()))
+	defer ts.Close()
+	missing := filepath.Join(t.TempDir(), "missing")
+	tests := []struct {
+		name    string
+		servers map[string]*MCPServerConfig
+		err     string
+		warning string
+		running []string
+	}{
+		{
+			name:    "working",
+			servers: map[string]*MCPServerConfig{"ok": {URL: ts.URL, Transport: "http"}},
+			running: []string{"ok"},
+		},
+		{
+			name: "optional failure",
+			servers: map[string]*MCPServerConfig{
+				"ok":     {URL: ts.URL, Transport: "http"},
+				"broken": {Command: missing},
+			},
+			warning: "WARNING: broken:",
+			running: []string{"ok"},
+		},
+		{
+			name: "required failure",
+			servers: map[string]*MCPServerConfig{
+				"ok":     {URL: ts.URL, Transport: "http"},
+				"broken": {Command: missing, Required: true},
+			},
+			err:     "broken:",
+			running: []string{"ok"},
+		},
+		{
+			name:    "optional timeout",
+			servers: map[string]*MCPServerConfig{"slow": {Command: "sleep", Args: []string{"30"}, Timeout: 0.2}},
+			warning: "WARNING: slow:",
+		},
+		{
+			name:    "required timeout",
+			servers: map[string]*MCPServerConfig{"slow": {Command: "sleep", Args: []string{"30"}, Timeout: 0.2, Required: true}},
+			err:     "slow:",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs strings.Builder
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+			m := sloppy.NewServerManager()
+			defer m.Close()
+			config := &Config{MCPServers: tt.servers}
+			start := time.Now()
+			err := config.AddServers(context.Background(), m)
+			// the per-server timeout is used instead of the default
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("starting the servers took %v", elapsed)
+			}
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+			if !strings.Contains(logs.String(), tt.warning) {
+				t.Fatalf("got logs %q, want %q", logs.String(), tt.warning)
+			}
+			if tt.warning == "" && strings.Contains(logs.String(), "WARNING") {
+				t.Fatalf("unexpected warning: %q", logs.String())
+			}
+			var running []string
+			for _, info := range m.Status() {
+				if info.Status == sloppy.ServerRunning {
+					running = append(running, info.Name)
+				}
+			}
+			if !slices.Equal(running, tt.running) {
+				t.Fatalf("got running servers %q, want %q", running, tt.running)
+			}
+		})
+	}
+}
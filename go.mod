@@ -6,6 +6,7 @@ require (
 	github.com/anthropics/anthropic-sdk-go v1.2.0
 	github.com/icholy/fuzzypatch v0.0.4
 	github.com/mark3labs/mcp-go v0.28.0
+	golang.org/x/term v0.32.0
 )
 
 require github.com/agnivade/levenshtein v1.2.1 // indirect
@@ -18,4 +19,5 @@ require (
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
 	github.com/yosida95/uritemplate/v3 v3.0.2 // indirect
+	golang.org/x/sys v0.33.0 // indirect
 )
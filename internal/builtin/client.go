@@ -1,11 +1,8 @@
 package builtin
 
 import (
-	"context"
-
-	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/icholy/sloppy/internal/mcpx"
 	"github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
@@ -13,31 +10,23 @@ type ToolProvider interface {
 	ServerTool() server.ServerTool
 }
 
-func NewClient(name string, providers ...ToolProvider) (*client.Client, error) {
+// NewServer returns an MCP server with the providers' tools.
+// When listChanged is true, the server notifies its clients
+// whenever tools are added or removed.
+func NewServer(name string, listChanged bool, providers ...ToolProvider) *server.MCPServer {
 	server := server.NewMCPServer(
 		name,
 		"0.0.0",
-		server.WithToolCapabilities(false),
+		server.WithToolCapabilities(listChanged),
 		server.WithRecovery(),
 	)
 	for _, p := range providers {
 		server.AddTools(p.ServerTool())
 	}
-	return client.NewInProcessClient(server)
+	return server
 }
 
-func Tools(name string, providers ...ToolProvider) []sloppy.Tool {
-	client, err := NewClient(name, providers...)
-	if err != nil {
-		panic(err)
-	}
-	ctx := context.Background()
-	if _, err := client.Initialize(ctx, mcp.InitializeRequest{}); err != nil {
-		panic(err)
-	}
-	tools, err := sloppy.ListClientTools(ctx, name, client)
-	if err != nil {
-		panic(err)
-	}
-	return tools
+// NewClient returns a client connected to a new server with the providers' tools.
+func NewClient(name string, providers ...ToolProvider) (*client.Client, error) {
+	return mcpx.NewInProcessClient(NewServer(name, true, providers...))
 }
@@ -0,0 +1,181 @@
+package builtin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_-]*)\s*\}\}`)
+
+// CommandTool is a user-defined tool which runs a command template.
+// The command is run directly rather than by a shell, and each argument
+// is substituted into a single word, so arguments can't inject commands.
+type CommandTool struct {
+	Name        string
+	Description string
+	Schema      mcp.ToolInputSchema
+	// Command is the program followed by its arguments. Each {{name}} is
+	// replaced with the named argument. A word which is only a placeholder
+	// is removed when the argument is missing, and expands to one word per
+	// element when the argument is an array.
+	Command []string
+	Dir     string
+	Timeout time.Duration
+}
+
+// Validate checks that the tool has a command, and that its
+// placeholders refer to parameters in the schema.
+func (t *CommandTool) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if len(t.Command) == 0 {
+		return fmt.Errorf("invalid tool: %s: command is required", t.Name)
+	}
+	for _, word := range t.Command {
+		for _, m := range placeholderRe.FindAllStringSubmatch(word, -1) {
+			if _, ok := t.Schema.Properties[m[1]]; !ok {
+				return fmt.Errorf("invalid tool: %s: unknown parameter: %q", t.Name, m[1])
+			}
+		}
+	}
+	return nil
+}
+
+func (t *CommandTool) ServerTool() server.ServerTool {
+	schema := t.Schema
+	if schema.Type == "" {
+		schema.Type = "object"
+	}
+	if schema.Properties == nil {
+		schema.Properties = map[string]any{}
+	}
+	return server.ServerTool{
+		Tool: mcp.Tool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		},
+		Handler: t.Handle,
+	}
+}
+
+func (t *CommandTool) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := req.Params.Arguments
+	for _, name := range t.Schema.Required {
+		if _, ok := args[name]; !ok {
+			return mcpx.NewToolResultErrorf("missing required argument: %q", name), nil
+		}
+	}
+	words, err := t.Expand(args)
+	if err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
+	}
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, words[0], words[1:]...)
+	cmd.Dir = t.Dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// background processes which keep the output open aren't waited for
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return mcpx.NewToolResultErrorf("%v: %s", err, output.String()), nil
+	}
+	return mcp.NewToolResultText(output.String()), nil
+}
+
+// Expand returns the command with the arguments substituted.
+func (t *CommandTool) Expand(args map[string]any) ([]string, error) {
+	var words []string
+	for i, word := range t.Command {
+		// a word which is only a placeholder may expand to zero or more words
+		if m := placeholderRe.FindStringSubmatch(word); i > 0 && m != nil && m[0] == word {
+			values, err := argumentWords(args[m[1]])
+			if err != nil {
+				return nil, fmt.Errorf("invalid argument: %s: %w", m[1], err)
+			}
+			words = append(words, values...)
+			continue
+		}
+		var err error
+		word = placeholderRe.ReplaceAllStringFunc(word, func(s string) string {
+			name := placeholderRe.FindStringSubmatch(s)[1]
+			value, e := argumentString(args[name])
+			if e != nil && err == nil {
+				err = fmt.Errorf("invalid argument: %s: %w", name, e)
+			}
+			return value
+		})
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	if len(words) == 0 || words[0] == "" {
+		return nil, fmt.Errorf("empty command")
+	}
+	return words, nil
+}
+
+// argumentWords returns the words for an argument which
+// takes up a whole word in the command.
+func argumentWords(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		var words []string
+		for _, elem := range v {
+			s, err := argumentString(elem)
+			if err != nil {
+				return nil, err
+			}
+			words = append(words, s)
+		}
+		return words, nil
+	default:
+		s, err := argumentString(v)
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+}
+
+// argumentString formats a scalar argument. Objects are encoded as JSON.
+func argumentString(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		return "", fmt.Errorf("arrays must be a whole word in the command")
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
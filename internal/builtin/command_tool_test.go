@@ -0,0 +1,211 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestCommandToolValidate(t *testing.T) {
+	schema := mcp.ToolInputSchema{
+		Type:       "object",
+		Properties: map[string]any{"path": map[string]any{"type": "string"}},
+	}
+	tests := []struct {
+		name string
+		tool CommandTool
+		err  string
+	}{
+		{
+			name: "valid",
+			tool: CommandTool{Name: "cat", Schema: schema, Command: []string{"cat", "--", "{{path}}"}},
+		},
+		{
+			name: "missing name",
+			tool: CommandTool{Command: []string{"true"}},
+			err:  "tool name is required",
+		},
+		{
+			name: "missing command",
+			tool: CommandTool{Name: "noop"},
+			err:  "invalid tool: noop: command is required",
+		},
+		{
+			name: "unknown parameter",
+			tool: CommandTool{Name: "cat", Schema: schema, Command: []string{"cat", "{{ file }}"}},
+			err:  `invalid tool: cat: unknown parameter: "file"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tool.Validate()
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.err {
+				t.Fatalf("got error %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestCommandToolExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command []string
+		args    map[string]any
+		words   []string
+		err     string
+	}{
+		{
+			name:    "string",
+			command: []string{"grep", "{{pattern}}", "{{path}}"},
+			args:    map[string]any{"pattern": "a b; rm -rf /", "path": "."},
+			words:   []string{"grep", "a b; rm -rf /", "."},
+		},
+		{
+			name:    "missing argument is removed",
+			command: []string{"ls", "{{path}}"},
+			args:    map[string]any{},
+			words:   []string{"ls"},
+		},
+		{
+			name:    "array",
+			command: []string{"go", "test", "{{packages}}"},
+			args:    map[string]any{"packages": []any{"./a", "./b"}},
+			words:   []string{"go", "test", "./a", "./b"},
+		},
+		{
+			name:    "embedded",
+			command: []string{"make", "ENV={{ env }}", "--jobs={{jobs}}", "--dry={{dry}}"},
+			args:    map[string]any{"env": "prod", "jobs": float64(4), "dry": true},
+			words:   []string{"make", "ENV=prod", "--jobs=4", "--dry=true"},
+		},
+		{
+			name:    "embedded missing argument",
+			command: []string{"echo", "x{{name}}y"},
+			args:    map[string]any{},
+			words:   []string{"echo", "xy"},
+		},
+		{
+			name:    "object",
+			command: []string{"echo", "{{value}}"},
+			args:    map[string]any{"value": map[string]any{"a": float64(1)}},
+			words:   []string{"echo", `{"a":1}`},
+		},
+		{
+			name:    "embedded array",
+			command: []string{"echo", "x{{values}}"},
+			args:    map[string]any{"values": []any{"a"}},
+			err:     "invalid argument: values: arrays must be a whole word in the command",
+		},
+		{
+			name:    "empty program",
+			command: []string{"{{program}}"},
+			args:    map[string]any{},
+			err:     "empty command",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := CommandTool{Name: "test", Command: tt.command}
+			words, err := tool.Expand(tt.args)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(words, tt.words) {
+				t.Fatalf("got %q, want %q", words, tt.words)
+			}
+		})
+	}
+}
+
+func TestCommandToolHandle(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	schema := mcp.ToolInputSchema{
+		Type:       "object",
+		Properties: map[string]any{"path": map[string]any{"type": "string"}},
+		Required:   []string{"path"},
+	}
+	tests := []struct {
+		name    string
+		tool    CommandTool
+		args    map[string]any
+		text    string
+		isError bool
+	}{
+		{
+			name: "dir",
+			tool: CommandTool{Schema: schema, Command: []string{"cat", "{{path}}"}, Dir: dir},
+			args: map[string]any{"path": "file.txt"},
+			text: "hello\n",
+		},
+		{
+			name:    "missing required",
+			tool:    CommandTool{Schema: schema, Command: []string{"cat", "{{path}}"}},
+			args:    map[string]any{},
+			text:    `missing required argument: "path"`,
+			isError: true,
+		},
+		{
+			name:    "failure",
+			tool:    CommandTool{Command: []string{"sh", "-c", "echo oops; exit 3"}},
+			text:    "exit status 3: oops\n",
+			isError: true,
+		},
+		{
+			name:    "timeout",
+			tool:    CommandTool{Command: []string{"sleep", "10"}, Timeout: 100 * time.Millisecond},
+			text:    "signal: killed: ",
+			isError: true,
+		},
+		{
+			name: "background process",
+			tool: CommandTool{Command: []string{"sh", "-c", "sleep 10 & echo started"}},
+			text: "started\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.tool.Name = "test"
+			var req mcp.CallToolRequest
+			req.Params.Arguments = tt.args
+			start := time.Now()
+			res, err := tt.tool.Handle(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("took %v", elapsed)
+			}
+			if res.IsError != tt.isError {
+				t.Fatalf("got IsError %v, want %v", res.IsError, tt.isError)
+			}
+			var text strings.Builder
+			for _, c := range res.Content {
+				if tc, ok := c.(mcp.TextContent); ok {
+					text.WriteString(tc.Text)
+				}
+			}
+			if text.String() != tt.text {
+				t.Fatalf("got %q, want %q", text.String(), tt.text)
+			}
+		})
+	}
+}
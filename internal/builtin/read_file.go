@@ -2,6 +2,9 @@ package builtin
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
 	"os"
 	"strings"
 
@@ -12,53 +15,48 @@ import (
 
 type ReadFile struct{}
 
+type readFileInput struct {
+	Path      string `param:"path,required" description:"The path of the file relative to the current working directory."`
+	StartLine int    `param:"start_line" description:"The 1-based line number to start reading from (inclusive). If not specified, starts from the first line."`
+	EndLine   int    `param:"end_line" description:"The 1-based line number to end reading at (inclusive). If not specified, reads to the end of the file."`
+}
+
 func (rf *ReadFile) ServerTool() server.ServerTool {
-	return server.ServerTool{
-		Tool: mcp.NewTool("read_file",
-			mcp.WithDescription("Read lines from a file, optionally specifying a start and end line (1-based, inclusive). Returns the file content as a string."),
-			mcp.WithString("path",
-				mcp.Required(),
-				mcp.Description("The path of the file relative to the current working directory."),
-			),
-			mcp.WithNumber("start_line",
-				mcp.Description("The 1-based line number to start reading from (inclusive). If not specified, starts from the first line."),
-			),
-			mcp.WithNumber("end_line",
-				mcp.Description("The 1-based line number to end reading at (inclusive). If not specified, reads to the end of the file."),
-			),
-		),
-		Handler: rf.Handle,
-	}
+	return mcpx.NewTypedTool("read_file",
+		"Read lines from a file, optionally specifying a start and end line (1-based, inclusive). Returns the file content as a string. PNG, JPEG, GIF, and WebP images are returned as images, and can't be read by line.",
+		rf.Run,
+	)
 }
 
-func (rf *ReadFile) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var input struct {
-		Path      string  `param:"path,required"`
-		StartLine float64 `param:"start_line"`
-		EndLine   float64 `param:"end_line"`
-	}
-	if err := mcpx.MapArguments(req.Params.Arguments, &input); err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
-	}
+func (rf *ReadFile) Run(ctx context.Context, input readFileInput) (*mcp.CallToolResult, error) {
 	if input.Path == "" {
-		return mcp.NewToolResultError("invalid input: path is required"), nil
+		return nil, fmt.Errorf("invalid input: path is required")
 	}
 	data, err := os.ReadFile(input.Path)
 	if err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to read file", err), nil
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if mimeType := http.DetectContentType(data); mcpx.IsSupportedImage(mimeType) {
+		if input.StartLine != 0 || input.EndLine != 0 {
+			return nil, fmt.Errorf("start_line and end_line can't be used with images")
+		}
+		if len(data) > mcpx.MaxImageSize {
+			return nil, fmt.Errorf("image too large: %d bytes (max %d)", len(data), mcpx.MaxImageSize)
+		}
+		return mcp.NewToolResultImage(input.Path, base64.StdEncoding.EncodeToString(data), mimeType), nil
 	}
 	lines := strings.SplitAfter(string(data), "\n")
 	nlines := len(lines)
 	start := 1
 	if input.StartLine > 0 {
-		start = int(input.StartLine) // use caller‑supplied value
+		start = input.StartLine
 	}
 	end := nlines
 	if input.EndLine > 0 {
-		end = int(input.EndLine) // use caller‑supplied value
+		end = input.EndLine
 	}
 	if start < 1 || start > nlines || end < start || end > nlines {
-		return mcpx.NewToolResultErrorf("invalid line range %d–%d (file has %d lines)", start, end, nlines), nil
+		return nil, fmt.Errorf("invalid line range %d–%d (file has %d lines)", start, end, nlines)
 	}
 	content := strings.Join(lines[start-1:end], "")
 	return mcp.NewToolResultText(content), nil
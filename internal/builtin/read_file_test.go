@@ -0,0 +1,97 @@
+package builtin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(text, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the PNG signature is enough for the type to be detected
+	png := filepath.Join(dir, "image.png")
+	if err := os.WriteFile(png, []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		input readFileInput
+		text  string
+		image bool
+		err   string
+	}{
+		{
+			name:  "whole file",
+			input: readFileInput{Path: text},
+			text:  "package main\n\nfunc main() {}\n",
+		},
+		{
+			name:  "line range",
+			input: readFileInput{Path: text, StartLine: 3, EndLine: 3},
+			text:  "func main() {}\n",
+		},
+		{
+			name:  "start line",
+			input: readFileInput{Path: text, StartLine: 2},
+			text:  "\nfunc main() {}\n",
+		},
+		{
+			name:  "invalid range",
+			input: readFileInput{Path: text, StartLine: 3, EndLine: 2},
+			err:   "invalid line range 3–2 (file has 4 lines)",
+		},
+		{
+			name:  "image",
+			input: readFileInput{Path: png},
+			image: true,
+		},
+		{
+			name:  "image with line range",
+			input: readFileInput{Path: png, StartLine: 1},
+			err:   "start_line and end_line can't be used with images",
+		},
+		{
+			name:  "missing",
+			input: readFileInput{Path: filepath.Join(dir, "missing")},
+			err:   "failed to read file: open " + filepath.Join(dir, "missing") + ": no such file or directory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rf ReadFile
+			res, err := rf.Run(context.Background(), tt.input)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotText string
+			var gotImage bool
+			for _, c := range res.Content {
+				switch c := c.(type) {
+				case mcp.TextContent:
+					gotText += c.Text
+				case mcp.ImageContent:
+					gotImage = c.MIMEType == "image/png"
+				}
+			}
+			if gotImage != tt.image {
+				t.Fatalf("got image %v, want %v", gotImage, tt.image)
+			}
+			if !tt.image && gotText != tt.text {
+				t.Fatalf("got text %q, want %q", gotText, tt.text)
+			}
+		})
+	}
+}
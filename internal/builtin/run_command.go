@@ -3,55 +3,56 @@ package builtin
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"io"
-	"os"
 	"os/exec"
 	"strings"
 
 	"github.com/icholy/sloppy/internal/mcpx"
 
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-type RunCommand struct{}
+// RunCommand runs shell commands. The command's output is always returned
+// to the model, and is also copied to Output when it's set.
+type RunCommand struct {
+	// Output receives a copy of the command's output.
+	Output io.Writer
+	// Stdin is the command's input. The command gets no input when it's nil.
+	Stdin io.Reader
+}
+
+type runCommandInput struct {
+	Command string `param:"command,required" description:"The shell command to execute."`
+}
 
 func (rc *RunCommand) ServerTool() server.ServerTool {
-	return server.ServerTool{
-		Tool: mcp.NewTool("run_command",
-			mcp.WithDescription(strings.Join([]string{
-				"Execute a shell command and return its output. Use this for running commands in the terminal.",
-				"Note: prefer using ripgrep instead of find if you're in a git repository.",
-			}, "")),
-			mcp.WithString("command",
-				mcp.Required(),
-				mcp.Description("The shell command to execute."),
-			),
-		),
-		Handler: rc.Handle,
-	}
+	return mcpx.NewTypedTool("run_command",
+		strings.Join([]string{
+			"Execute a shell command and return its output. Use this for running commands in the terminal.",
+			"Note: prefer using ripgrep instead of find if you're in a git repository.",
+		}, ""),
+		rc.Run,
+	)
 }
 
-func (rc *RunCommand) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var input struct {
-		Command string `param:"command,required"`
-	}
-	if err := mcpx.MapArguments(req.Params.Arguments, &input); err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
-	}
+func (rc *RunCommand) Run(ctx context.Context, input runCommandInput) (string, error) {
 	if input.Command == "" {
-		return mcp.NewToolResultError("invalid arguments: command cannot be empty"), nil
+		return "", fmt.Errorf("invalid arguments: command cannot be empty")
 	}
 
 	cmd := exec.CommandContext(ctx, "bash", "-c", input.Command)
-	cmd.Stdin = os.Stdin
+	cmd.Stdin = rc.Stdin
 
-	// Both capture and display output
 	var output bytes.Buffer
-	cmd.Stdout = io.MultiWriter(os.Stdout, &output)
-	cmd.Stderr = io.MultiWriter(os.Stderr, &output)
+	var w io.Writer = &output
+	if rc.Output != nil {
+		w = io.MultiWriter(rc.Output, &output)
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
 	if err := cmd.Run(); err != nil {
-		return mcpx.NewToolResultErrorf("%v: %s", err, output.String()), nil
+		return "", fmt.Errorf("%v: %s", err, output.String())
 	}
-	return mcp.NewToolResultText(output.String()), nil
+	return output.String(), nil
 }
@@ -2,45 +2,154 @@ package builtin
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/icholy/sloppy/internal/mcpx"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
 type WriteFile struct{}
 
+type writeFileInput struct {
+	Path      string `param:"path,required" description:"The path of the file relative to the current working directory."`
+	Content   string `param:"content,required" description:"The content to write to the file."`
+	Append    bool   `param:"append" description:"Append the content to the end of the file instead of replacing it."`
+	Normalize bool   `param:"normalize" description:"Convert line endings to match the existing file. When replacing the file, the trailing newline is matched as well."`
+}
+
 func (wf *WriteFile) ServerTool() server.ServerTool {
-	return server.ServerTool{
-		Tool: mcp.NewTool("write_file",
-			mcp.WithDescription("Write content to a file, replacing its contents or creating it if it doesn't exist."),
-			mcp.WithString("path",
-				mcp.Required(),
-				mcp.Description("The path of the file relative to the current working directory."),
-			),
-			mcp.WithString("content",
-				mcp.Required(),
-				mcp.Description("The content to write to the file."),
-			),
-		),
-		Handler: wf.Handle,
+	return mcpx.NewTypedTool("write_file",
+		strings.Join([]string{
+			"Write content to a file, replacing its contents or creating it if it doesn't exist.",
+			"Missing parent directories are created.",
+		}, " "),
+		wf.Run,
+	)
+}
+
+func (wf *WriteFile) Run(ctx context.Context, input writeFileInput) (string, error) {
+	if input.Path == "" {
+		return "", fmt.Errorf("invalid input: path is required")
+	}
+	// write through symlinks instead of replacing them
+	path := input.Path
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
 	}
+	content := input.Content
+	perm := fs.FileMode(0644)
+	// hard links are replaced by the rename, so they're written in place
+	inPlace := false
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if info.IsDir() {
+			return "", fmt.Errorf("failed to write file: %s is a directory", input.Path)
+		}
+		// the rename would replace files which can't be written to
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return "", fmt.Errorf("failed to write file: %w", err)
+		}
+		f.Close()
+		perm = info.Mode().Perm()
+		inPlace = linkCount(info) > 1
+		if input.Normalize || input.Append {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", fmt.Errorf("failed to read file: %w", err)
+			}
+			existing := string(data)
+			if input.Append {
+				// the existing trailing newline is kept when appending
+				if input.Normalize {
+					content = normalizeLineEndings(content, existing)
+				}
+				content = existing + content
+			} else if input.Normalize {
+				content = normalizeContent(content, existing)
+			}
+		}
+	case errors.Is(err, fs.ErrNotExist):
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("failed to create directory: %w", err)
+		}
+	default:
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if inPlace {
+		err = os.WriteFile(path, []byte(content), perm)
+	} else {
+		err = writeFileAtomic(path, []byte(content), perm)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+	if input.Append {
+		return "File appended", nil
+	}
+	return "File written", nil
 }
 
-func (wf *WriteFile) Handle(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	var input struct {
-		Path    string `param:"path,required"`
-		Content string `param:"content,required"`
+// normalizeContent converts the line endings of content to match existing
+// and adds or removes the trailing newline so it matches as well.
+func normalizeContent(content, existing string) string {
+	if existing == "" {
+		return content
 	}
-	if err := mcpx.MapArguments(req.Params.Arguments, &input); err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
+	content = strings.TrimRight(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if strings.HasSuffix(existing, "\n") {
+		content += "\n"
 	}
-	if input.Path == "" {
-		return mcp.NewToolResultError("invalid input: path is required"), nil
+	return normalizeLineEndings(content, existing)
+}
+
+// normalizeLineEndings converts the line endings of content to match existing.
+func normalizeLineEndings(content, existing string) string {
+	if existing == "" {
+		return content
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if strings.Contains(existing, "\r\n") {
+		content = strings.ReplaceAll(content, "\n", "\r\n")
+	}
+	return content
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory
+// as name and renames it into place so readers never see a partial file.
+func writeFileAtomic(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
 	}
-	if err := os.WriteFile(input.Path, []byte(input.Content), 0644); err != nil {
-		return mcp.NewToolResultErrorFromErr("failed to write file", err), nil
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
 	}
-	return mcp.NewToolResultText("File written"), nil
+	return nil
 }
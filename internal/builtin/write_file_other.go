@@ -0,0 +1,11 @@
+//go:build !unix
+
+package builtin
+
+import "io/fs"
+
+// linkCount returns the number of hard links to the file.
+// It's always 1 on platforms which don't report it.
+func linkCount(info fs.FileInfo) uint64 {
+	return 1
+}
@@ -0,0 +1,219 @@
+package builtin
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup creates the files in dir
+		setup func(t *testing.T, dir string)
+		input writeFileInput
+		// files are the expected contents relative to dir
+		files map[string]string
+		mode  fs.FileMode
+		err   string
+		root  bool
+	}{
+		{
+			name:  "create",
+			input: writeFileInput{Path: "new.txt", Content: "hello\n"},
+			files: map[string]string{"new.txt": "hello\n"},
+			mode:  0o644,
+		},
+		{
+			name:  "create parent directories",
+			input: writeFileInput{Path: "a/b/c.txt", Content: "nested"},
+			files: map[string]string{"a/b/c.txt": "nested"},
+		},
+		{
+			name: "replace",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "old\n", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "new\n"},
+			files: map[string]string{"file.txt": "new\n"},
+		},
+		{
+			name: "preserve mode",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "run.sh"), "echo old\n", 0o755)
+			},
+			input: writeFileInput{Path: "run.sh", Content: "echo new\n"},
+			files: map[string]string{"run.sh": "echo new\n"},
+			mode:  0o755,
+		},
+		{
+			name: "normalize crlf",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "a\r\nb\r\n", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "x\ny", Normalize: true},
+			files: map[string]string{"file.txt": "x\r\ny\r\n"},
+		},
+		{
+			name: "normalize trailing newline",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "a", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "b\n\n", Normalize: true},
+			files: map[string]string{"file.txt": "b"},
+		},
+		{
+			name: "append",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "a\n", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "b\n", Append: true},
+			files: map[string]string{"file.txt": "a\nb\n"},
+		},
+		{
+			name:  "append to missing file",
+			input: writeFileInput{Path: "file.txt", Content: "b\n", Append: true},
+			files: map[string]string{"file.txt": "b\n"},
+		},
+		{
+			name: "append normalize keeps trailing newline",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "a", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "b\n", Append: true, Normalize: true},
+			files: map[string]string{"file.txt": "ab\n"},
+		},
+		{
+			name: "append normalize crlf",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "a\r\n", 0o644)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "b\nc\n", Append: true, Normalize: true},
+			files: map[string]string{"file.txt": "a\r\nb\r\nc\r\n"},
+		},
+		{
+			name: "symlink",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "target.txt"), "old\n", 0o644)
+				if err := os.Symlink("target.txt", filepath.Join(dir, "link.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			input: writeFileInput{Path: "link.txt", Content: "new\n"},
+			files: map[string]string{"target.txt": "new\n", "link.txt": "new\n"},
+		},
+		{
+			name: "hard link",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "old\n", 0o644)
+				if err := os.Link(filepath.Join(dir, "file.txt"), filepath.Join(dir, "link.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+			input: writeFileInput{Path: "link.txt", Content: "new\n"},
+			files: map[string]string{"file.txt": "new\n", "link.txt": "new\n"},
+		},
+		{
+			name: "directory",
+			setup: func(t *testing.T, dir string) {
+				if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			},
+			input: writeFileInput{Path: "sub", Content: "x"},
+			err:   "is a directory",
+		},
+		{
+			name: "read only",
+			setup: func(t *testing.T, dir string) {
+				writeTestFile(t, filepath.Join(dir, "file.txt"), "old\n", 0o444)
+			},
+			input: writeFileInput{Path: "file.txt", Content: "new\n"},
+			files: map[string]string{"file.txt": "old\n"},
+			err:   "permission denied",
+			root:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.root && os.Geteuid() == 0 {
+				t.Skip("root can write to read-only files")
+			}
+			dir := t.TempDir()
+			if tt.setup != nil {
+				tt.setup(t, dir)
+			}
+			input := tt.input
+			input.Path = filepath.Join(dir, input.Path)
+			_, err := (&WriteFile{}).Run(context.Background(), input)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for name, want := range tt.files {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(data) != want {
+					t.Fatalf("%s: got %q, want %q", name, data, want)
+				}
+			}
+			if tt.mode != 0 {
+				info, err := os.Stat(input.Path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != tt.mode {
+					t.Fatalf("got mode %v, want %v", info.Mode().Perm(), tt.mode)
+				}
+			}
+			// the temporary files are always removed
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if strings.HasSuffix(e.Name(), ".tmp") {
+					t.Fatalf("temporary file was left behind: %s", e.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestWriteFileKeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "target.txt"), "old\n", 0o644)
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&WriteFile{}).Run(context.Background(), writeFileInput{Path: link, Content: "new\n"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Fatal("symlink was replaced by a file")
+	}
+}
+
+func writeTestFile(t *testing.T, name, data string, perm fs.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(data), perm); err != nil {
+		t.Fatal(err)
+	}
+	// the umask may have removed some of the permissions
+	if err := os.Chmod(name, perm); err != nil {
+		t.Fatal(err)
+	}
+}
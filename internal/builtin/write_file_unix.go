@@ -0,0 +1,16 @@
+//go:build unix
+
+package builtin
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file.
+func linkCount(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
@@ -0,0 +1,136 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/icholy/sloppy/internal/termcolor"
+)
+
+// syntax describes how to highlight a language.
+type syntax struct {
+	comment  string
+	quotes   string
+	keywords map[string]bool
+}
+
+func words(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+var (
+	goSyntax = &syntax{
+		comment:  "//",
+		quotes:   "\"'`",
+		keywords: words("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false"),
+	}
+	pythonSyntax = &syntax{
+		comment:  "#",
+		quotes:   "\"'",
+		keywords: words("and as assert async await break class continue def del elif else except finally for from global if import in is lambda nonlocal not or pass raise return try while with yield None True False self"),
+	}
+	jsSyntax = &syntax{
+		comment:  "//",
+		quotes:   "\"'`",
+		keywords: words("async await break case catch class const continue default delete do else export extends finally for from function if import in instanceof interface let new of return static super switch this throw try type typeof var void while yield null undefined true false"),
+	}
+	rustSyntax = &syntax{
+		comment:  "//",
+		quotes:   "\"",
+		keywords: words("as async await break const continue crate else enum extern fn for if impl in let loop match mod move mut pub ref return self Self static struct super trait type unsafe use where while true false"),
+	}
+	shellSyntax = &syntax{
+		comment:  "#",
+		quotes:   "\"'",
+		keywords: words("if then else elif fi for in do done while until case esac function return export local"),
+	}
+	cSyntax = &syntax{
+		comment:  "//",
+		quotes:   "\"'",
+		keywords: words("auto break case catch char class const continue default delete do double else enum extern final float for if import int long new null package private protected public return short static struct switch this throw try typedef union unsigned void volatile while true false"),
+	}
+	jsonSyntax = &syntax{
+		quotes:   "\"",
+		keywords: words("true false null"),
+	}
+	yamlSyntax = &syntax{
+		comment:  "#",
+		quotes:   "\"'",
+		keywords: words("true false null"),
+	}
+)
+
+func lookupSyntax(lang string) *syntax {
+	switch strings.ToLower(lang) {
+	case "go", "golang":
+		return goSyntax
+	case "py", "python":
+		return pythonSyntax
+	case "js", "javascript", "jsx", "ts", "typescript", "tsx":
+		return jsSyntax
+	case "rs", "rust":
+		return rustSyntax
+	case "sh", "bash", "shell", "zsh", "console":
+		return shellSyntax
+	case "c", "h", "cpp", "c++", "java", "cs", "c#", "kotlin", "swift":
+		return cSyntax
+	case "json":
+		return jsonSyntax
+	case "yaml", "yml", "toml":
+		return yamlSyntax
+	default:
+		return nil
+	}
+}
+
+// Highlight highlights a line of code. Strings and comments which span
+// multiple lines are not supported. Lines in unknown languages are
+// returned as is.
+func Highlight(line, lang string) string {
+	syn := lookupSyntax(lang)
+	if syn == nil {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case syn.comment != "" && strings.HasPrefix(line[i:], syn.comment):
+			b.WriteString(termcolor.Text(line[i:], termcolor.Gray))
+			return b.String()
+		case strings.IndexByte(syn.quotes, c) >= 0:
+			end := i + 1
+			for end < len(line) && line[end] != c {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(line))
+			b.WriteString(termcolor.Text(line[i:end], termcolor.Green))
+			i = end
+		case isWordChar(c):
+			end := i
+			for end < len(line) && isWordChar(line[end]) {
+				end++
+			}
+			word := line[i:end]
+			switch {
+			case syn.keywords[word]:
+				b.WriteString(termcolor.Text(word, termcolor.Purple))
+			case word[0] >= '0' && word[0] <= '9':
+				b.WriteString(termcolor.Text(word, termcolor.Yellow))
+			default:
+				b.WriteString(word)
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
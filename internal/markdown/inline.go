@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/icholy/sloppy/internal/termcolor"
+)
+
+// ANSI codes which turn off a single style so that styles can be nested.
+const (
+	boldOff      = "\u001b[22m"
+	italicOff    = "\u001b[23m"
+	underlineOff = "\u001b[24m"
+	strikeOn     = "\u001b[9m"
+	strikeOff    = "\u001b[29m"
+	colorOff     = "\u001b[39m"
+)
+
+var linkRe = regexp.MustCompile(`^\[([^\]]+)\]\(([^)\s]+)\)`)
+
+// renderInline renders code spans, emphasis, and links.
+func renderInline(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_[]()#+-.!|~", s[i+1]) >= 0:
+			b.WriteByte(s[i+1])
+			i += 2
+		case s[i] == '`':
+			n := 1
+			for i+n < len(s) && s[i+n] == '`' {
+				n++
+			}
+			delim := s[i : i+n]
+			end := strings.Index(s[i+n:], delim)
+			if end < 0 {
+				b.WriteString(delim)
+				i += n
+				continue
+			}
+			b.WriteString(termcolor.Cyan + s[i+n:i+n+end] + colorOff)
+			i += 2*n + end
+		case strings.HasPrefix(s[i:], "**"), strings.HasPrefix(s[i:], "__"), strings.HasPrefix(s[i:], "~~"):
+			delim := s[i : i+2]
+			end := closing(s, i+2, delim)
+			if end < 0 || !opens(s, i, len(delim)) {
+				b.WriteString(delim)
+				i += 2
+				continue
+			}
+			inner := renderInline(s[i+2 : end])
+			if delim == "~~" {
+				b.WriteString(strikeOn + inner + strikeOff)
+			} else {
+				b.WriteString(termcolor.Bold + inner + boldOff)
+			}
+			i = end + 2
+		case s[i] == '*', s[i] == '_':
+			delim := s[i : i+1]
+			end := closing(s, i+1, delim)
+			if end < 0 || !opens(s, i, 1) {
+				b.WriteString(delim)
+				i++
+				continue
+			}
+			b.WriteString(termcolor.Italic + renderInline(s[i+1:end]) + italicOff)
+			i = end + 1
+		case s[i] == '[':
+			m := linkRe.FindStringSubmatch(s[i:])
+			if m == nil {
+				b.WriteByte(s[i])
+				i++
+				continue
+			}
+			b.WriteString(termcolor.Underline + termcolor.Blue + renderInline(m[1]) + colorOff + underlineOff)
+			if m[2] != m[1] {
+				b.WriteString(termcolor.Text(" ("+m[2]+")", termcolor.Gray))
+			}
+			i += len(m[0])
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// opens reports whether the delimiter at s[i:i+n] can open emphasis.
+// It must be followed by a non-space, and underscores inside words
+// (like snake_case) are ignored.
+func opens(s string, i, n int) bool {
+	if i+n >= len(s) || s[i+n] == ' ' {
+		return false
+	}
+	return s[i] != '_' || i == 0 || !isWordChar(s[i-1])
+}
+
+// closing returns the index of the delimiter which closes the emphasis
+// starting at start, or -1 if there isn't one.
+func closing(s string, start int, delim string) int {
+	for j := start + 1; j+len(delim) <= len(s); j++ {
+		if s[j:j+len(delim)] != delim || s[j-1] == ' ' {
+			continue
+		}
+		if delim[0] == '_' && j+len(delim) < len(s) && isWordChar(s[j+len(delim)]) {
+			continue
+		}
+		return j
+	}
+	return -1
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,171 @@
+// Package markdown renders markdown for the terminal using ANSI codes.
+package markdown
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/icholy/sloppy/internal/termcolor"
+)
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	listRe    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	quoteRe   = regexp.MustCompile(`^\s*>\s?(.*)$`)
+	ruleRe    = regexp.MustCompile(`^\s*(\*\s*){3,}$|^\s*(-\s*){3,}$|^\s*(_\s*){3,}$`)
+	fenceRe   = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.-]*)")
+	ansiRe    = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+)
+
+// Render renders the markdown source with ANSI codes.
+// Markdown which isn't understood is left as is.
+func Render(src string) string {
+	var out []string
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := fenceRe.FindStringSubmatch(line); m != nil {
+			// code block
+			out = append(out, termcolor.Text(line, termcolor.Gray))
+			lang := m[2]
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), m[1]) {
+					out = append(out, termcolor.Text(lines[i], termcolor.Gray))
+					break
+				}
+				out = append(out, Highlight(lines[i], lang))
+			}
+			continue
+		}
+		if isTableRow(line) {
+			start := i
+			for i+1 < len(lines) && isTableRow(lines[i+1]) {
+				i++
+			}
+			out = append(out, renderTable(lines[start:i+1])...)
+			continue
+		}
+		out = append(out, renderLine(line))
+	}
+	return strings.Join(out, "\n")
+}
+
+func renderLine(line string) string {
+	if m := headingRe.FindStringSubmatch(line); m != nil {
+		color := termcolor.Bold
+		if len(m[1]) <= 2 {
+			color += termcolor.Cyan
+		}
+		return termcolor.Text(m[2], color)
+	}
+	if ruleRe.MatchString(line) {
+		return termcolor.Text(strings.Repeat("─", 40), termcolor.Gray)
+	}
+	if m := listRe.FindStringSubmatch(line); m != nil {
+		marker := m[2]
+		if len(marker) == 1 && strings.Contains("-*+", marker) {
+			marker = "•"
+		}
+		return m[1] + termcolor.Text(marker, termcolor.Yellow) + " " + renderInline(m[3])
+	}
+	if m := quoteRe.FindStringSubmatch(line); m != nil {
+		return termcolor.Text("│ ", termcolor.Gray) + termcolor.Italic + renderInline(m[1]) + termcolor.Reset
+	}
+	return renderInline(line)
+}
+
+func isTableRow(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) > 1 && strings.HasPrefix(line, "|")
+}
+
+// splitRow splits a table row into its cells.
+func splitRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	var cells []string
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			b.WriteByte('|')
+			i++
+		case line[i] == '|':
+			cells = append(cells, strings.TrimSpace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return append(cells, strings.TrimSpace(b.String()))
+}
+
+var delimiterRe = regexp.MustCompile(`^:?-+:?$`)
+
+func isDelimiterRow(cells []string) bool {
+	for _, c := range cells {
+		if !delimiterRe.MatchString(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// renderTable aligns the table's columns. The rows above the
+// delimiter row are the header.
+func renderTable(lines []string) []string {
+	var rows [][]string
+	header := -1
+	for _, line := range lines {
+		cells := splitRow(line)
+		if header == -1 && len(rows) > 0 && isDelimiterRow(cells) {
+			header = len(rows)
+			continue
+		}
+		for i, c := range cells {
+			cells[i] = renderInline(c)
+		}
+		rows = append(rows, cells)
+	}
+	var widths []int
+	for _, row := range rows {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleLen(c))
+		}
+	}
+	var out []string
+	sep := termcolor.Text(" │ ", termcolor.Gray)
+	for r, row := range rows {
+		if r == header {
+			var rule []string
+			for _, w := range widths {
+				rule = append(rule, strings.Repeat("─", w))
+			}
+			out = append(out, termcolor.Text(" "+strings.Join(rule, "─┼─")+" ", termcolor.Gray))
+		}
+		var cells []string
+		for i, w := range widths {
+			var c string
+			if i < len(row) {
+				c = row[i]
+			}
+			if r < header {
+				c = termcolor.Text(c, termcolor.Bold)
+			}
+			cells = append(cells, c+strings.Repeat(" ", w-visibleLen(c)))
+		}
+		out = append(out, " "+strings.Join(cells, sep))
+	}
+	return out
+}
+
+// visibleLen returns the number of characters which are visible
+// when s is written to a terminal.
+func visibleLen(s string) int {
+	return utf8.RuneCountInString(ansiRe.ReplaceAllString(s, ""))
+}
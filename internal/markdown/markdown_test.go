@@ -0,0 +1,163 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/icholy/sloppy/internal/termcolor"
+)
+
+const (
+	bold   = termcolor.Bold
+	italic = termcolor.Italic
+	reset  = termcolor.Reset
+)
+
+func TestRenderInline(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{name: "plain", in: "hello world", out: "hello world"},
+		{name: "code", in: "run `go test`", out: "run " + termcolor.Cyan + "go test" + colorOff},
+		{name: "double backtick code", in: "``a`b``", out: termcolor.Cyan + "a`b" + colorOff},
+		{name: "unclosed code", in: "a `b", out: "a `b"},
+		{name: "bold", in: "**bold**", out: bold + "bold" + boldOff},
+		{name: "underscore bold", in: "__bold__", out: bold + "bold" + boldOff},
+		{name: "italic", in: "*it*", out: italic + "it" + italicOff},
+		{name: "nested", in: "**a *b* c**", out: bold + "a " + italic + "b" + italicOff + " c" + boldOff},
+		{name: "strike", in: "~~old~~", out: strikeOn + "old" + strikeOff},
+		{name: "snake case", in: "snake_case_name", out: "snake_case_name"},
+		{name: "spaced asterisk", in: "2 * 3 * 4", out: "2 * 3 * 4"},
+		{name: "escape", in: `\*not italic\*`, out: "*not italic*"},
+		{
+			name: "link",
+			in:   "[docs](https://example.com)",
+			out:  termcolor.Underline + termcolor.Blue + "docs" + colorOff + underlineOff + termcolor.Text(" (https://example.com)", termcolor.Gray),
+		},
+		{
+			name: "autolink",
+			in:   "[https://example.com](https://example.com)",
+			out:  termcolor.Underline + termcolor.Blue + "https://example.com" + colorOff + underlineOff,
+		},
+		{name: "not a link", in: "[a] (b)", out: "[a] (b)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderInline(tt.in); got != tt.out {
+				t.Fatalf("renderInline(%q) = %q, want %q", tt.in, got, tt.out)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{name: "heading", in: "# Title", out: termcolor.Text("Title", bold+termcolor.Cyan)},
+		{name: "small heading", in: "### Title ###", out: termcolor.Text("Title", bold)},
+		{name: "list", in: "- item", out: termcolor.Text("•", termcolor.Yellow) + " item"},
+		{name: "numbered list", in: "  1. item", out: "  " + termcolor.Text("1.", termcolor.Yellow) + " item"},
+		{name: "quote", in: "> quoted", out: termcolor.Text("│ ", termcolor.Gray) + italic + "quoted" + reset},
+		{name: "rule", in: "---", out: termcolor.Text("────────────────────────────────────────", termcolor.Gray)},
+		{
+			name: "code block",
+			in:   "```go\nreturn **x**\n```",
+			out: termcolor.Text("```go", termcolor.Gray) + "\n" +
+				termcolor.Text("return", termcolor.Purple) + " **x**\n" +
+				termcolor.Text("```", termcolor.Gray),
+		},
+		{
+			name: "unclosed code block",
+			in:   "~~~\n# not a heading",
+			out:  termcolor.Text("~~~", termcolor.Gray) + "\n# not a heading",
+		},
+		{
+			name: "table",
+			in:   "| a | bb |\n|---|---|\n| ccc | d |",
+			out: " " + termcolor.Text("a", bold) + "  " + termcolor.Text(" │ ", termcolor.Gray) + termcolor.Text("bb", bold) + "\n" +
+				termcolor.Text(" ────┼─── ", termcolor.Gray) + "\n" +
+				" ccc" + termcolor.Text(" │ ", termcolor.Gray) + "d ",
+		},
+		{
+			name: "table with escaped pipe",
+			in:   `| a\|b |`,
+			out:  " a|b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in); got != tt.out {
+				t.Fatalf("Render(%q) =\n%q\nwant\n%q", tt.in, got, tt.out)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		lang string
+		out  string
+	}{
+		{name: "unknown language", line: "func main()", lang: "cobol", out: "func main()"},
+		{
+			name: "keywords",
+			line: "func main()",
+			lang: "go",
+			out:  termcolor.Text("func", termcolor.Purple) + " main()",
+		},
+		{
+			name: "string",
+			line: `x := "a\"b"`,
+			lang: "golang",
+			out:  "x := " + termcolor.Text(`"a\"b"`, termcolor.Green),
+		},
+		{
+			name: "comment",
+			line: "x = 1 # note",
+			lang: "python",
+			out:  "x = " + termcolor.Text("1", termcolor.Yellow) + " " + termcolor.Text("# note", termcolor.Gray),
+		},
+		{
+			name: "comment in string",
+			line: `echo "#x"`,
+			lang: "sh",
+			out:  "echo " + termcolor.Text(`"#x"`, termcolor.Green),
+		},
+		{
+			name: "unterminated string",
+			line: `"abc`,
+			lang: "json",
+			out:  termcolor.Text(`"abc`, termcolor.Green),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Highlight(tt.line, tt.lang); got != tt.out {
+				t.Fatalf("Highlight(%q, %q) = %q, want %q", tt.line, tt.lang, got, tt.out)
+			}
+		})
+	}
+}
+
+func TestVisibleLen(t *testing.T) {
+	tests := []struct {
+		in  string
+		len int
+	}{
+		{in: "", len: 0},
+		{in: "abc", len: 3},
+		{in: "héllo", len: 5},
+		{in: termcolor.Text("abc", termcolor.Bold+termcolor.Cyan), len: 3},
+	}
+	for _, tt := range tests {
+		if got := visibleLen(tt.in); got != tt.len {
+			t.Errorf("visibleLen(%q) = %d, want %d", tt.in, got, tt.len)
+		}
+	}
+}
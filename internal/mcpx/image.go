@@ -0,0 +1,14 @@
+package mcpx
+
+// MaxImageSize is the largest image accepted by the model.
+const MaxImageSize = 5 << 20
+
+// IsSupportedImage reports whether the model accepts images of this type.
+func IsSupportedImage(mimeType string) bool {
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,127 @@
+package mcpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+var sessionID atomic.Int64
+
+// inProcessSession is the server side of an InProcessTransport.
+type inProcessSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+	initialized   atomic.Bool
+}
+
+func (s *inProcessSession) SessionID() string { return s.id }
+func (s *inProcessSession) Initialize()       { s.initialized.Store(true) }
+func (s *inProcessSession) Initialized() bool { return s.initialized.Load() }
+
+func (s *inProcessSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// InProcessTransport connects directly to a server in the same process.
+// Unlike transport.InProcessTransport, it registers a session with the
+// server so that server notifications are delivered to the client.
+type InProcessTransport struct {
+	server  *server.MCPServer
+	session *inProcessSession
+	done    chan struct{}
+	once    sync.Once
+
+	mu      sync.RWMutex
+	handler func(mcp.JSONRPCNotification)
+}
+
+// NewInProcessTransport returns a transport connected to s.
+func NewInProcessTransport(s *server.MCPServer) *InProcessTransport {
+	return &InProcessTransport{
+		server: s,
+		session: &inProcessSession{
+			id:            fmt.Sprintf("in-process-%d", sessionID.Add(1)),
+			notifications: make(chan mcp.JSONRPCNotification, 100),
+		},
+		done: make(chan struct{}),
+	}
+}
+
+// NewInProcessClient returns a started client connected to s.
+func NewInProcessClient(s *server.MCPServer) (*client.Client, error) {
+	c := client.NewClient(NewInProcessTransport(s))
+	if err := c.Start(context.Background()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (t *InProcessTransport) Start(ctx context.Context) error {
+	if err := t.server.RegisterSession(ctx, t.session); err != nil {
+		return err
+	}
+	go func() {
+		for {
+			select {
+			case <-t.done:
+				return
+			case n := <-t.session.notifications:
+				t.mu.RLock()
+				if t.handler != nil {
+					t.handler(n)
+				}
+				t.mu.RUnlock()
+			}
+		}
+	}()
+	return nil
+}
+
+func (t *InProcessTransport) SendRequest(ctx context.Context, req transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	msg := t.server.HandleMessage(t.server.WithContext(ctx, t.session), data)
+	data, err = json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response message: %w", err)
+	}
+	var res transport.JSONRPCResponse
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response message: %w", err)
+	}
+	return &res, nil
+}
+
+func (t *InProcessTransport) SendNotification(ctx context.Context, n mcp.JSONRPCNotification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+	t.server.HandleMessage(t.server.WithContext(ctx, t.session), data)
+	return nil
+}
+
+func (t *InProcessTransport) SetNotificationHandler(handler func(mcp.JSONRPCNotification)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.handler = handler
+}
+
+// Close unregisters the session from the server.
+func (t *InProcessTransport) Close() error {
+	t.once.Do(func() {
+		t.server.UnregisterSession(context.Background(), t.session.id)
+		close(t.done)
+	})
+	return nil
+}
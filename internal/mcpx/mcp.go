@@ -4,6 +4,7 @@ package mcpx
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -12,57 +13,185 @@ import (
 // MapArguments copies properties from args into v.
 //
 // The target must be a pointer to a struct whose fields carry `param` tags.
-// Tags have the form `param:"name[,required]"`.  If args contains a key
-// with no matching field, if a required value is missing, or if a value’s
-// type is not assignable to the field, an error is returned.
+// Tags have the form `param:"name[,required]"`. If args contains a key
+// with no matching field, if a required value is missing, if a value is
+// not in the field's `enum`, or if a value's type can't be converted to the
+// field's type, an error is returned. Missing values are set from the
+// field's `default` tag. JSON numbers are converted to integer fields when
+// they're whole, and nested objects and arrays are mapped recursively.
 func MapArguments(args map[string]any, v any) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
 		return fmt.Errorf("target must be *struct")
 	}
-	rv = rv.Elem()
-	rt := rv.Type()
+	return mapStruct("", args, rv.Elem())
+}
 
-	fields := make(map[string]int)
-	required := make(map[string]bool)
-	for i := range rt.NumField() {
-		f := rt.Field(i)
-		tag := f.Tag.Get("param")
-		if tag == "" {
+func mapStruct(prefix string, args map[string]any, rv reflect.Value) error {
+	ps := params(rv.Type())
+	fields := make(map[string]param)
+	for _, p := range ps {
+		fields[p.name] = p
+	}
+	for k, val := range args {
+		p, ok := fields[k]
+		if !ok {
+			return fmt.Errorf("unknown parameter %q", prefix+k)
+		}
+		fv := rv.Field(p.index)
+		if !fv.CanSet() {
+			return fmt.Errorf("cannot set field %q", prefix+k)
+		}
+		if val == nil {
+			continue
+		}
+		if err := assign(prefix+k, fv, val); err != nil {
+			return err
+		}
+		if enum, ok := p.enum(); ok {
+			if err := checkEnum(prefix+k, fv, enum); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range ps {
+		if args[p.name] != nil {
 			continue
 		}
-		name, flags, _ := strings.Cut(tag, ",")
-		if name == "" {
-			name = strings.ToLower(f.Name)
+		if p.required {
+			return fmt.Errorf("missing required parameter %q", prefix+p.name)
 		}
-		fields[name] = i
-		if flags == "require" {
-			required[name] = true
+		if def, ok := p.field.Tag.Lookup("default"); ok {
+			if err := setString(rv.Field(p.index), def); err != nil {
+				return fmt.Errorf("invalid default for parameter %q: %w", prefix+p.name, err)
+			}
 		}
 	}
+	return nil
+}
 
-	set := make(map[string]bool)
-	for k, val := range args {
-		idx, ok := fields[k]
+// assign sets fv to val, converting it to the field's type.
+func assign(name string, fv reflect.Value, val any) error {
+	vv := reflect.ValueOf(val)
+	if vv.Type().AssignableTo(fv.Type()) {
+		fv.Set(vv)
+		return nil
+	}
+	mismatch := fmt.Errorf("parameter %q expects %s, got %s", name, fv.Type(), vv.Type())
+	switch fv.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(fv.Type().Elem())
+		if err := assign(name, elem.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(elem)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, ok := val.(float64)
 		if !ok {
-			return fmt.Errorf("unknown parameter %q", k)
+			return mismatch
 		}
-		fv := rv.Field(idx)
-		if !fv.CanSet() {
-			return fmt.Errorf("cannot set field %q", k)
+		n := int64(f)
+		if float64(n) != f || fv.OverflowInt(n) {
+			return fmt.Errorf("parameter %q expects %s, got %v", name, fv.Type(), f)
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, ok := val.(float64)
+		if !ok {
+			return mismatch
 		}
-		vv := reflect.ValueOf(val)
-		if !vv.Type().AssignableTo(fv.Type()) {
-			return fmt.Errorf("parameter %q expects %s, got %s", k, fv.Type(), vv.Type())
+		n := uint64(f)
+		if f < 0 || float64(n) != f || fv.OverflowUint(n) {
+			return fmt.Errorf("parameter %q expects %s, got %v", name, fv.Type(), f)
 		}
-		fv.Set(vv)
-		set[k] = true
+		fv.SetUint(n)
+	case reflect.Float32:
+		f, ok := val.(float64)
+		if !ok {
+			return mismatch
+		}
+		fv.SetFloat(f)
+	case reflect.Slice:
+		elems, ok := val.([]any)
+		if !ok {
+			return mismatch
+		}
+		s := reflect.MakeSlice(fv.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if elem == nil {
+				continue
+			}
+			if err := assign(fmt.Sprintf("%s[%d]", name, i), s.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		fv.Set(s)
+	case reflect.Array:
+		elems, ok := val.([]any)
+		if !ok {
+			return mismatch
+		}
+		if len(elems) != fv.Len() {
+			return fmt.Errorf("parameter %q expects %d elements, got %d", name, fv.Len(), len(elems))
+		}
+		a := reflect.New(fv.Type()).Elem()
+		for i, elem := range elems {
+			if elem == nil {
+				continue
+			}
+			if err := assign(fmt.Sprintf("%s[%d]", name, i), a.Index(i), elem); err != nil {
+				return err
+			}
+		}
+		fv.Set(a)
+	case reflect.Map:
+		m, ok := val.(map[string]any)
+		if !ok || fv.Type().Key().Kind() != reflect.String {
+			return mismatch
+		}
+		mv := reflect.MakeMapWithSize(fv.Type(), len(m))
+		for k, elem := range m {
+			ev := reflect.New(fv.Type().Elem()).Elem()
+			if elem != nil {
+				if err := assign(name+"."+k, ev, elem); err != nil {
+					return err
+				}
+			}
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(fv.Type().Key()), ev)
+		}
+		fv.Set(mv)
+	case reflect.Struct:
+		m, ok := val.(map[string]any)
+		if !ok {
+			return mismatch
+		}
+		return mapStruct(name+".", m, fv)
+	default:
+		return mismatch
 	}
+	return nil
+}
 
-	for k := range required {
-		if !set[k] {
-			return fmt.Errorf("missing required parameter %q", k)
+// checkEnum returns an error if the value, or any of its elements
+// if it's a slice or array, is not one of the allowed values.
+func checkEnum(name string, fv reflect.Value, enum []string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil
 		}
+		fv = fv.Elem()
+	}
+	if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+		for i := range fv.Len() {
+			if err := checkEnum(fmt.Sprintf("%s[%d]", name, i), fv.Index(i), enum); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	value := fmt.Sprint(fv.Interface())
+	if !slices.Contains(enum, value) {
+		return fmt.Errorf("parameter %q must be one of %s, got %q", name, strings.Join(enum, ", "), value)
 	}
 	return nil
 }
@@ -0,0 +1,232 @@
+package mcpx
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// param is a struct field with a `param` tag.
+type param struct {
+	name     string
+	index    int
+	required bool
+	field    reflect.StructField
+}
+
+// enum returns the allowed values from the `enum` tag.
+func (p param) enum() ([]string, bool) {
+	tag, ok := p.field.Tag.Lookup("enum")
+	if !ok {
+		return nil, false
+	}
+	return strings.Split(tag, ","), true
+}
+
+// params returns the fields of the struct type which have `param` tags.
+func params(t reflect.Type) []param {
+	var ps []param
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("param")
+		if tag == "" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		ps = append(ps, param{
+			name:     name,
+			index:    i,
+			required: slices.Contains(strings.Split(flags, ","), "required"),
+			field:    f,
+		})
+	}
+	return ps
+}
+
+// WithParams returns a tool option which sets the input schema using the
+// tagged fields of v, a struct or pointer to a struct. See InputSchema.
+// It panics if the schema can't be generated.
+func WithParams(v any) mcp.ToolOption {
+	schema, err := InputSchema(v)
+	if err != nil {
+		panic(err)
+	}
+	return func(t *mcp.Tool) {
+		t.InputSchema = schema
+	}
+}
+
+// InputSchema generates a tool input schema from the fields of v, a struct
+// or pointer to a struct, which carry `param` tags. In addition to
+// the tags understood by MapArguments, fields may have the tags:
+//
+//	description:"text"  describes the parameter.
+//	enum:"a,b,c"        lists the allowed values.
+//	default:"value"     is used when the parameter is missing.
+//
+// Nested structs, slices, arrays, and maps with string keys are supported.
+func InputSchema(v any) (mcp.ToolInputSchema, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return mcp.ToolInputSchema{}, fmt.Errorf("params must be a struct, got %v", t)
+	}
+	properties, required, err := structSchema(t)
+	if err != nil {
+		return mcp.ToolInputSchema{}, err
+	}
+	return mcp.ToolInputSchema{
+		Type:       "object",
+		Properties: properties,
+		Required:   required,
+	}, nil
+}
+
+func structSchema(t reflect.Type) (map[string]any, []string, error) {
+	properties := map[string]any{}
+	var required []string
+	for _, p := range params(t) {
+		schema, err := typeSchema(p.field.Type)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parameter %q: %w", p.name, err)
+		}
+		if desc := p.field.Tag.Get("description"); desc != "" {
+			schema["description"] = desc
+		}
+		if enum, ok := p.enum(); ok {
+			// the values of a slice's enum apply to its items
+			target, elem := schema, p.field.Type
+			if items, ok := schema["items"].(map[string]any); ok {
+				target, elem = items, elem.Elem()
+			}
+			var values []any
+			for _, s := range enum {
+				value, err := parseValue(s, elem)
+				if err != nil {
+					return nil, nil, fmt.Errorf("parameter %q: invalid enum: %w", p.name, err)
+				}
+				values = append(values, value)
+			}
+			target["enum"] = values
+		}
+		if def, ok := p.field.Tag.Lookup("default"); ok {
+			value, err := parseValue(def, p.field.Type)
+			if err != nil {
+				return nil, nil, fmt.Errorf("parameter %q: invalid default: %w", p.name, err)
+			}
+			schema["default"] = value
+		}
+		properties[p.name] = schema
+		if p.required {
+			required = append(required, p.name)
+		}
+	}
+	return properties, required, nil
+}
+
+func typeSchema(t reflect.Type) (map[string]any, error) {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			schema["minItems"] = t.Len()
+			schema["maxItems"] = t.Len()
+		}
+		return schema, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type: %s", t.Key())
+		}
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		properties, required, err := structSchema(t)
+		if err != nil {
+			return nil, err
+		}
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema, nil
+	case reflect.Interface:
+		return map[string]any{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", t)
+	}
+}
+
+// parseValue parses a tag value as the type.
+func parseValue(s string, t reflect.Type) (any, error) {
+	v := reflect.New(t).Elem()
+	if err := setString(v, s); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// setString sets v to the value parsed from s.
+func setString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		v.Set(reflect.New(v.Type().Elem()))
+		return setString(v.Elem(), s)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type: %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package mcpx
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type testFile struct {
+	Path string `param:"path,required"`
+	Mode string `param:"mode" enum:"r,w" default:"r"`
+}
+
+type testParams struct {
+	Name    string            `param:"name,required" description:"the name"`
+	Count   int               `param:"count" default:"1"`
+	Size    uint8             `param:"size"`
+	Ratio   float32           `param:"ratio"`
+	Verbose *bool             `param:"verbose"`
+	Tags    []string          `param:"tags" enum:"a,b"`
+	Point   [2]int            `param:"point"`
+	Labels  map[string]string `param:"labels"`
+	File    *testFile         `param:"file"`
+	Files   []testFile        `param:"files"`
+	Extra   any               `param:"extra"`
+	Ignored string
+}
+
+func TestMapArguments(t *testing.T) {
+	verbose := true
+	tests := []struct {
+		name string
+		args string
+		want testParams
+		err  string
+	}{
+		{
+			name: "required only",
+			args: `{"name": "x"}`,
+			want: testParams{Name: "x", Count: 1},
+		},
+		{
+			name: "all",
+			args: `{
+				"name": "x",
+				"count": 3,
+				"size": 255,
+				"ratio": 0.5,
+				"verbose": true,
+				"tags": ["a", "b"],
+				"point": [1, 2],
+				"labels": {"k": "v"},
+				"file": {"path": "main.go"},
+				"files": [{"path": "a.go", "mode": "w"}],
+				"extra": [1, "two"]
+			}`,
+			want: testParams{
+				Name:    "x",
+				Count:   3,
+				Size:    255,
+				Ratio:   0.5,
+				Verbose: &verbose,
+				Tags:    []string{"a", "b"},
+				Point:   [2]int{1, 2},
+				Labels:  map[string]string{"k": "v"},
+				File:    &testFile{Path: "main.go", Mode: "r"},
+				Files:   []testFile{{Path: "a.go", Mode: "w"}},
+				Extra:   []any{float64(1), "two"},
+			},
+		},
+		{
+			name: "null is missing",
+			args: `{"name": "x", "count": null}`,
+			want: testParams{Name: "x", Count: 1},
+		},
+		{
+			name: "missing required",
+			args: `{}`,
+			err:  `missing required parameter "name"`,
+		},
+		{
+			name: "unknown",
+			args: `{"name": "x", "Ignored": "y"}`,
+			err:  `unknown parameter "Ignored"`,
+		},
+		{
+			name: "wrong type",
+			args: `{"name": 1}`,
+			err:  `parameter "name" expects string, got float64`,
+		},
+		{
+			name: "fractional integer",
+			args: `{"name": "x", "count": 1.5}`,
+			err:  `parameter "count" expects int, got 1.5`,
+		},
+		{
+			name: "overflow",
+			args: `{"name": "x", "size": 256}`,
+			err:  `parameter "size" expects uint8, got 256`,
+		},
+		{
+			name: "negative unsigned",
+			args: `{"name": "x", "size": -1}`,
+			err:  `parameter "size" expects uint8, got -1`,
+		},
+		{
+			name: "enum",
+			args: `{"name": "x", "tags": ["a", "c"]}`,
+			err:  `parameter "tags[1]" must be one of a, b, got "c"`,
+		},
+		{
+			name: "array length",
+			args: `{"name": "x", "point": [1, 2, 3]}`,
+			err:  `parameter "point" expects 2 elements, got 3`,
+		},
+		{
+			name: "array element",
+			args: `{"name": "x", "point": [1, "2"]}`,
+			err:  `parameter "point[1]" expects int, got string`,
+		},
+		{
+			name: "nested required",
+			args: `{"name": "x", "files": [{"mode": "w"}]}`,
+			err:  `missing required parameter "files[0].path"`,
+		},
+		{
+			name: "nested enum",
+			args: `{"name": "x", "file": {"path": "a", "mode": "x"}}`,
+			err:  `parameter "file.mode" must be one of r, w, got "x"`,
+		},
+		{
+			name: "map value",
+			args: `{"name": "x", "labels": {"k": 1}}`,
+			err:  `parameter "labels.k" expects string, got float64`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var args map[string]any
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			var got testParams
+			err := MapArguments(args, &got)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMapArgumentsTarget(t *testing.T) {
+	var s testParams
+	if err := MapArguments(nil, s); err == nil {
+		t.Fatal("expected an error for a non-pointer")
+	}
+	var n int
+	if err := MapArguments(nil, &n); err == nil {
+		t.Fatal("expected an error for a non-struct")
+	}
+}
+
+func TestInputSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		v      any
+		schema string
+		err    string
+	}{
+		{
+			name: "params",
+			v:    testParams{},
+			schema: `{
+				"type": "object",
+				"required": ["name"],
+				"properties": {
+					"name": {"type": "string", "description": "the name"},
+					"count": {"type": "integer", "default": 1},
+					"size": {"type": "integer", "minimum": 0},
+					"ratio": {"type": "number"},
+					"verbose": {"type": "boolean"},
+					"tags": {"type": "array", "items": {"type": "string", "enum": ["a", "b"]}},
+					"point": {"type": "array", "items": {"type": "integer"}, "minItems": 2, "maxItems": 2},
+					"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+					"file": {
+						"type": "object",
+						"required": ["path"],
+						"properties": {
+							"path": {"type": "string"},
+							"mode": {"type": "string", "enum": ["r", "w"], "default": "r"}
+						}
+					},
+					"files": {
+						"type": "array",
+						"items": {
+							"type": "object",
+							"required": ["path"],
+							"properties": {
+								"path": {"type": "string"},
+								"mode": {"type": "string", "enum": ["r", "w"], "default": "r"}
+							}
+						}
+					},
+					"extra": {}
+				}
+			}`,
+		},
+		{
+			name:   "pointer",
+			v:      &testFile{},
+			schema: `{"type": "object", "required": ["path"], "properties": {"path": {"type": "string"}, "mode": {"type": "string", "enum": ["r", "w"], "default": "r"}}}`,
+		},
+		{
+			name:   "empty",
+			v:      struct{}{},
+			schema: `{"type": "object", "properties": {}}`,
+		},
+		{
+			name: "not a struct",
+			v:    "x",
+			err:  "params must be a struct, got string",
+		},
+		{
+			name: "unsupported type",
+			v: struct {
+				C chan int `param:"c"`
+			}{},
+			err: `parameter "c": unsupported type: chan int`,
+		},
+		{
+			name: "unsupported map key",
+			v: struct {
+				M map[int]string `param:"m"`
+			}{},
+			err: `parameter "m": unsupported map key type: int`,
+		},
+		{
+			name: "invalid enum",
+			v: struct {
+				N int `param:"n" enum:"1,x"`
+			}{},
+			err: `parameter "n": invalid enum: strconv.ParseInt: parsing "x": invalid syntax`,
+		},
+		{
+			name: "invalid default",
+			v: struct {
+				B bool `param:"b" default:"maybe"`
+			}{},
+			err: `parameter "b": invalid default: strconv.ParseBool: parsing "maybe": invalid syntax`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := InputSchema(tt.v)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want mcp.ToolInputSchema
+			if err := json.Unmarshal([]byte(tt.schema), &want); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := normalize(t, schema), normalize(t, want); !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// normalize round trips the schema through JSON so
+// that schemas with different Go types can be compared.
+func normalize(t *testing.T, schema mcp.ToolInputSchema) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
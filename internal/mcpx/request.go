@@ -0,0 +1,54 @@
+package mcpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// RPCError is an error response from the server.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+// IsMethodNotFound reports whether the server doesn't implement the method.
+func IsMethodNotFound(err error) bool {
+	var rpcErr *RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == mcp.METHOD_NOT_FOUND
+}
+
+var requestID atomic.Int64
+
+// Request sends a request using the client's transport and decodes the
+// result into v. Unlike the client's methods, error responses are returned
+// as an *RPCError so that their codes can be checked.
+func Request(ctx context.Context, c *client.Client, method string, params any, v any) error {
+	res, err := c.GetTransport().SendRequest(ctx, transport.JSONRPCRequest{
+		JSONRPC: mcp.JSONRPC_VERSION,
+		// a string id can't collide with the client's numeric ids
+		ID:     mcp.NewRequestId(fmt.Sprintf("mcpx-%d", requestID.Add(1))),
+		Method: method,
+		Params: params,
+	})
+	if err != nil {
+		return fmt.Errorf("transport error: %w", err)
+	}
+	if res.Error != nil {
+		return &RPCError{Code: res.Error.Code, Message: res.Error.Message}
+	}
+	if err := json.Unmarshal(res.Result, v); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %s: %w", method, err)
+	}
+	return nil
+}
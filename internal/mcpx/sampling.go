@@ -0,0 +1,78 @@
+package mcpx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// SamplingHandler handles sampling/createMessage requests sent by a server.
+type SamplingHandler func(ctx context.Context, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+
+// serverRequest is a JSON-RPC request sent from the server to the client.
+type serverRequest struct {
+	ID     mcp.RequestId   `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+// requestReader reads newline delimited JSON-RPC messages and removes the
+// requests sent by the server. The transport only understands responses
+// and notifications, so the requests are passed to handle instead.
+type requestReader struct {
+	r      *bufio.Reader
+	handle func(req serverRequest)
+	buf    []byte
+	err    error
+}
+
+func (r *requestReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		line, err := r.r.ReadBytes('\n')
+		r.err = err
+		if len(line) == 0 {
+			continue
+		}
+		var req serverRequest
+		if json.Unmarshal(line, &req) == nil && req.Method != "" && !req.ID.IsNil() {
+			go r.handle(req)
+			continue
+		}
+		r.buf = line
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// handleServerRequest returns the response to a server request.
+func handleServerRequest(ctx context.Context, req serverRequest, sampling SamplingHandler) any {
+	switch req.Method {
+	case string(mcp.MethodPing):
+		return mcp.NewJSONRPCResponse(req.ID, mcp.Result{})
+	case "sampling/createMessage":
+		if sampling == nil {
+			break
+		}
+		var params mcp.CreateMessageRequest
+		params.Method = req.Method
+		if err := json.Unmarshal(req.Params, &params.Params); err != nil {
+			return mcp.NewJSONRPCError(req.ID, mcp.INVALID_PARAMS, err.Error(), nil)
+		}
+		res, err := sampling(ctx, params)
+		if err != nil {
+			return mcp.NewJSONRPCError(req.ID, mcp.INTERNAL_ERROR, err.Error(), nil)
+		}
+		return mcp.JSONRPCResponse{
+			JSONRPC: mcp.JSONRPC_VERSION,
+			ID:      req.ID,
+			Result:  res,
+		}
+	}
+	return mcp.NewJSONRPCError(req.ID, mcp.METHOD_NOT_FOUND, "method not found: "+req.Method, nil)
+}
@@ -0,0 +1,163 @@
+package mcpx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRequestReader(t *testing.T) {
+	lines := []string{
+		`{"jsonrpc":"2.0","id":1,"result":{}}`,
+		`{"jsonrpc":"2.0","id":"a","method":"ping"}`,
+		`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"sampling/createMessage","params":{}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"method not found"}}`,
+	}
+	input := strings.Join(lines, "\n")
+	requests := make(chan serverRequest, len(lines))
+	r := &requestReader{
+		r: bufio.NewReader(strings.NewReader(input)),
+		handle: func(req serverRequest) {
+			requests <- req
+		},
+	}
+	// read a byte at a time to check that lines are split across reads
+	data, err := io.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{lines[0], lines[2], lines[4], lines[5]}, "\n")
+	if string(data) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", data, want)
+	}
+	var methods []string
+	for range 2 {
+		req := <-requests
+		methods = append(methods, fmt.Sprint(req.ID.Value())+" "+req.Method)
+	}
+	slices.Sort(methods)
+	if want := []string{"2 sampling/createMessage", "a ping"}; !slices.Equal(methods, want) {
+		t.Fatalf("got requests %q, want %q", methods, want)
+	}
+}
+
+func TestHandleServerRequest(t *testing.T) {
+	sampling := func(ctx context.Context, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+		if req.Params.MaxTokens == 0 {
+			return nil, errors.New("rejected")
+		}
+		return &mcp.CreateMessageResult{
+			SamplingMessage: mcp.SamplingMessage{
+				Role:    mcp.RoleAssistant,
+				Content: mcp.NewTextContent("hello"),
+			},
+			Model: "test",
+		}, nil
+	}
+	tests := []struct {
+		name     string
+		method   string
+		params   string
+		sampling SamplingHandler
+		// result is the expected JSON result, or empty for an error
+		result string
+		code   int
+	}{
+		{
+			name:   "ping",
+			method: "ping",
+			result: `{}`,
+		},
+		{
+			name:     "sampling",
+			method:   "sampling/createMessage",
+			params:   `{"messages":[],"maxTokens":10}`,
+			sampling: sampling,
+			result:   `{"role":"assistant","content":{"type":"text","text":"hello"},"model":"test"}`,
+		},
+		{
+			name:     "sampling error",
+			method:   "sampling/createMessage",
+			params:   `{"messages":[]}`,
+			sampling: sampling,
+			code:     mcp.INTERNAL_ERROR,
+		},
+		{
+			name:     "invalid params",
+			method:   "sampling/createMessage",
+			params:   `{"maxTokens":"ten"}`,
+			sampling: sampling,
+			code:     mcp.INVALID_PARAMS,
+		},
+		{
+			name:   "sampling not supported",
+			method: "sampling/createMessage",
+			params: `{"messages":[]}`,
+			code:   mcp.METHOD_NOT_FOUND,
+		},
+		{
+			name:     "unknown method",
+			method:   "roots/list",
+			sampling: sampling,
+			code:     mcp.METHOD_NOT_FOUND,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := serverRequest{
+				ID:     mcp.NewRequestId(int64(7)),
+				Method: tt.method,
+				Params: json.RawMessage(tt.params),
+			}
+			data, err := json.Marshal(handleServerRequest(context.Background(), req, tt.sampling))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var res struct {
+				ID     int64           `json:"id"`
+				Result json.RawMessage `json:"result"`
+				Error  *struct {
+					Code int `json:"code"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(data, &res); err != nil {
+				t.Fatal(err)
+			}
+			if res.ID != 7 {
+				t.Fatalf("got id %d, want 7: %s", res.ID, data)
+			}
+			if tt.result == "" {
+				if res.Error == nil || res.Error.Code != tt.code {
+					t.Fatalf("got %s, want error code %d", data, tt.code)
+				}
+				return
+			}
+			if res.Error != nil {
+				t.Fatalf("unexpected error: %s", data)
+			}
+			var got, want any
+			if err := json.Unmarshal(res.Result, &got); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.result), &want); err != nil {
+				t.Fatal(err)
+			}
+			gotJSON, _ := json.Marshal(got)
+			wantJSON, _ := json.Marshal(want)
+			if string(gotJSON) != string(wantJSON) {
+				t.Fatalf("got result %s, want %s", gotJSON, wantJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package mcpx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// CommandTransport is a stdio transport which communicates with a
+// subprocess. Unlike transport.Stdio, the caller has full control over how
+// the exec.Cmd is configured (environment, working directory, etc).
+type CommandTransport struct {
+	*transport.Stdio
+	cmd    *exec.Cmd
+	stdin  *lockedWriter
+	stdout io.ReadCloser
+	// eof is closed once stdout has been read to the end, which
+	// must happen before the subprocess is waited on.
+	eof      chan struct{}
+	eofOnce  sync.Once
+	done     chan struct{}
+	err      error
+	sampling atomic.Pointer[SamplingHandler]
+}
+
+// NewCommandTransport starts cmd and returns a transport connected to its
+// stdin and stdout.
+func NewCommandTransport(cmd *exec.Cmd) (*CommandTransport, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	t := &CommandTransport{
+		cmd:    cmd,
+		stdin:  &lockedWriter{w: stdin},
+		stdout: stdout,
+		eof:    make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	requests := &requestReader{
+		r:      bufio.NewReader(closedReader(t.readStdout)),
+		handle: t.handleRequest,
+	}
+	t.Stdio = transport.NewIO(requests, t.stdin, stderr)
+	go func() {
+		// exec.Cmd.Wait closes stdout, so it's only called
+		// once the last message has been read.
+		<-t.eof
+		t.err = cmd.Wait()
+		close(t.done)
+	}()
+	return t, nil
+}
+
+// readStdout reads from the subprocess's stdout and records when
+// there is nothing left to read.
+func (t *CommandTransport) readStdout(p []byte) (int, error) {
+	n, err := t.stdout.Read(p)
+	if err != nil {
+		t.eofOnce.Do(func() { close(t.eof) })
+	}
+	return n, err
+}
+
+// Done returns a channel which is closed when the subprocess exits.
+func (t *CommandTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+// Err returns the subprocess exit error after Done is closed.
+func (t *CommandTransport) Err() error {
+	<-t.done
+	return t.err
+}
+
+// SendRequest sends a request to the subprocess and waits for the response.
+// Unlike transport.Stdio, pending requests fail if the subprocess exits.
+func (t *CommandTransport) SendRequest(ctx context.Context, req transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	res, err := t.Stdio.SendRequest(ctx, req)
+	if err != nil {
+		select {
+		case <-t.done:
+			if t.err != nil {
+				return nil, fmt.Errorf("server exited: %w", t.err)
+			}
+			return nil, fmt.Errorf("server exited")
+		default:
+		}
+	}
+	return res, err
+}
+
+// Close closes the pipes and waits for the subprocess to exit.
+// The subprocess is killed if it doesn't exit within a few seconds.
+func (t *CommandTransport) Close() error {
+	// the pipes have already been closed if the subprocess exited
+	if err := t.Stdio.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	// the transport stops reading once it's closed, so discard
+	// the remaining output until the subprocess exits
+	go io.Copy(io.Discard, readerFunc(t.readStdout))
+	select {
+	case <-t.done:
+	case <-time.After(3 * time.Second):
+		t.cmd.Process.Kill()
+		// a child process may still be holding stdout open
+		t.stdout.Close()
+	}
+	return t.Err()
+}
+
+// SetSamplingHandler sets the handler for sampling/createMessage requests
+// sent by the subprocess. Requests fail if no handler is set.
+func (t *CommandTransport) SetSamplingHandler(h SamplingHandler) {
+	t.sampling.Store(&h)
+}
+
+// handleRequest responds to a request sent by the subprocess.
+func (t *CommandTransport) handleRequest(req serverRequest) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var h SamplingHandler
+	if p := t.sampling.Load(); p != nil {
+		h = *p
+	}
+	res := handleServerRequest(ctx, req, h)
+	data, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+	t.stdin.Write(append(data, '\n'))
+}
+
+// NewCommandClient starts cmd and returns a client connected to it.
+// The client has already been started. If sampling is not nil, the client
+// advertises the sampling capability and forwards requests to it.
+func NewCommandClient(ctx context.Context, cmd *exec.Cmd, sampling SamplingHandler) (*client.Client, error) {
+	t, err := NewCommandTransport(cmd)
+	if err != nil {
+		return nil, err
+	}
+	var opts []client.ClientOption
+	if sampling != nil {
+		t.SetSamplingHandler(sampling)
+		opts = append(opts, client.WithClientCapabilities(mcp.ClientCapabilities{
+			Sampling: &struct{}{},
+		}))
+	}
+	c := client.NewClient(t, opts...)
+	if err := c.Start(ctx); err != nil {
+		t.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// lockedWriter serializes writes from the transport and request handlers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+func (w *lockedWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Close()
+}
+
+// readerFunc is an adapter to allow the use of ordinary functions as readers.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) { return f(p) }
+
+// closedReader reports reads from a closed pipe as io.EOF. The pipe is closed
+// when the subprocess is killed, which would otherwise be logged as an error
+// by the transport's read loop.
+type closedReader readerFunc
+
+func (r closedReader) Read(p []byte) (int, error) {
+	n, err := r(p)
+	if errors.Is(err, os.ErrClosed) {
+		err = io.EOF
+	}
+	return n, err
+}
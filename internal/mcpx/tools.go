@@ -0,0 +1,41 @@
+package mcpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ListTools lists the server's tools along with their input schemas,
+// keyed by tool name. The schemas are returned as sent by the server
+// because mcp.ToolInputSchema only keeps the type, properties, and
+// required keywords.
+func ListTools(ctx context.Context, c *client.Client) ([]mcp.Tool, map[string]map[string]any, error) {
+	var data json.RawMessage
+	if err := Request(ctx, c, string(mcp.MethodToolsList), nil, &data); err != nil {
+		return nil, nil, err
+	}
+	var result mcp.ListToolsResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal tools: %w", err)
+	}
+	var raw struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal tool schemas: %w", err)
+	}
+	schemas := map[string]map[string]any{}
+	for _, t := range raw.Tools {
+		if t.InputSchema != nil {
+			schemas[t.Name] = t.InputSchema
+		}
+	}
+	return result.Tools, schemas, nil
+}
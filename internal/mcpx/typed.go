@@ -0,0 +1,63 @@
+package mcpx
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TypedHandler handles a tool call with decoded arguments.
+type TypedHandler[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// NewTypedTool returns a tool whose input schema is generated from In, a
+// struct with `param` tags (see InputSchema). Arguments are decoded using
+// MapArguments, and errors from the handler are returned as tool errors.
+//
+// The handler's output becomes the result: a *mcp.CallToolResult is used as
+// is, strings and byte slices are returned as text, and all other values
+// are encoded as JSON. It panics if the schema can't be generated.
+func NewTypedTool[In, Out any](name, description string, handler TypedHandler[In, Out], opts ...mcp.ToolOption) server.ServerTool {
+	var in In
+	opts = append([]mcp.ToolOption{
+		mcp.WithDescription(description),
+		WithParams(in),
+	}, opts...)
+	return server.ServerTool{
+		Tool: mcp.NewTool(name, opts...),
+		Handler: func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			var in In
+			if err := MapArguments(req.Params.Arguments, &in); err != nil {
+				return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
+			}
+			out, err := handler(ctx, in)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return NewToolResult(out), nil
+		},
+	}
+}
+
+// NewToolResult converts a value into a tool result. See NewTypedTool.
+// Values which can't be encoded as JSON are returned as tool errors.
+func NewToolResult(v any) *mcp.CallToolResult {
+	switch v := v.(type) {
+	case *mcp.CallToolResult:
+		if v == nil {
+			return mcp.NewToolResultText("")
+		}
+		return v
+	case string:
+		return mcp.NewToolResultText(v)
+	case []byte:
+		return mcp.NewToolResultText(string(v))
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("failed to marshal tool result", err)
+		}
+		return mcp.NewToolResultText(string(data))
+	}
+}
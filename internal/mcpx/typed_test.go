@@ -0,0 +1,90 @@
+package mcpx
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestNewToolResult(t *testing.T) {
+	tests := []struct {
+		name    string
+		v       any
+		text    string
+		isError bool
+	}{
+		{name: "string", v: "hello", text: "hello"},
+		{name: "bytes", v: []byte("hello"), text: "hello"},
+		{name: "result", v: mcp.NewToolResultError("oops"), text: "oops", isError: true},
+		{name: "nil result", v: (*mcp.CallToolResult)(nil), text: ""},
+		{name: "json", v: map[string]int{"a": 1}, text: "{\n  \"a\": 1\n}"},
+		{name: "unsupported", v: make(chan int), text: "failed to marshal tool result: json: unsupported type: chan int", isError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewToolResult(tt.v)
+			if res.IsError != tt.isError {
+				t.Fatalf("got IsError %v, want %v", res.IsError, tt.isError)
+			}
+			if got := resultText(res); got != tt.text {
+				t.Fatalf("got %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestNewTypedTool(t *testing.T) {
+	type input struct {
+		Name string `param:"name,required"`
+	}
+	tool := NewTypedTool("greet", "greets someone", func(ctx context.Context, in input) (string, error) {
+		if in.Name == "nobody" {
+			return "", errors.New("no one to greet")
+		}
+		return "hello " + in.Name, nil
+	})
+	if tool.Tool.Description != "greets someone" {
+		t.Fatalf("got description %q", tool.Tool.Description)
+	}
+	if _, ok := tool.Tool.InputSchema.Properties["name"]; !ok {
+		t.Fatalf("missing name parameter: %v", tool.Tool.InputSchema)
+	}
+	tests := []struct {
+		name    string
+		args    map[string]any
+		text    string
+		isError bool
+	}{
+		{name: "ok", args: map[string]any{"name": "bob"}, text: "hello bob"},
+		{name: "handler error", args: map[string]any{"name": "nobody"}, text: "no one to greet", isError: true},
+		{name: "invalid arguments", args: map[string]any{}, text: `failed to parse arguments: missing required parameter "name"`, isError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req mcp.CallToolRequest
+			req.Params.Arguments = tt.args
+			res, err := tool.Handler(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.IsError != tt.isError {
+				t.Fatalf("got IsError %v, want %v", res.IsError, tt.isError)
+			}
+			if got := resultText(res); got != tt.text {
+				t.Fatalf("got %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	var text string
+	for _, c := range res.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text += tc.Text
+		}
+	}
+	return text
+}
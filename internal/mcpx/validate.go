@@ -0,0 +1,243 @@
+package mcpx
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ValidationError is an argument which doesn't match the schema.
+type ValidationError struct {
+	// Path is the location of the argument, for example "files[0].path".
+	// It's empty for errors about the arguments object itself.
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidateArguments checks the arguments against a tool's input schema,
+// a decoded JSON schema object. It supports the type, required, enum,
+// const, properties, additionalProperties, and items keywords, and ignores
+// all others. All of the problems are returned, joined using errors.Join.
+func ValidateArguments(schema map[string]any, args map[string]any) error {
+	if args == nil {
+		args = map[string]any{}
+	}
+	return errors.Join(validate("", schema, args)...)
+}
+
+// SchemaMap converts an input schema to a JSON schema object.
+func SchemaMap(schema mcp.ToolInputSchema) map[string]any {
+	m := map[string]any{"type": schema.Type}
+	if schema.Type == "" {
+		m["type"] = "object"
+	}
+	if schema.Properties != nil {
+		m["properties"] = schema.Properties
+	}
+	if len(schema.Required) > 0 {
+		m["required"] = schema.Required
+	}
+	return m
+}
+
+func validate(path string, schema any, v any) []error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		if schema == false {
+			return []error{&ValidationError{Path: path, Message: "not allowed"}}
+		}
+		return nil
+	}
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		return []error{&ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, got %s", typeNames(t), jsonType(v)),
+		}}
+	}
+	var errs []error
+	if enum, ok := s["enum"].([]any); ok && !slices.ContainsFunc(enum, func(e any) bool { return equal(e, v) }) {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("must be one of %s, got %s", formatValues(enum), formatValue(v)),
+		})
+	}
+	if c, ok := s["const"]; ok && !equal(c, v) {
+		errs = append(errs, &ValidationError{
+			Path:    path,
+			Message: fmt.Sprintf("must be %s, got %s", formatValue(c), formatValue(v)),
+		})
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		properties, _ := s["properties"].(map[string]any)
+		var names []string
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := properties[name]
+			if !ok {
+				extra, ok := s["additionalProperties"]
+				if !ok {
+					continue
+				}
+				if extra == false {
+					errs = append(errs, &ValidationError{Path: join(path, name), Message: "unknown property"})
+					continue
+				}
+				prop = extra
+			}
+			errs = append(errs, validate(join(path, name), prop, v[name])...)
+		}
+		for _, name := range stringValues(s["required"]) {
+			if _, ok := v[name]; !ok {
+				errs = append(errs, &ValidationError{Path: join(path, name), Message: "missing required property"})
+			}
+		}
+	case []any:
+		if items, ok := s["items"]; ok {
+			for i, elem := range v {
+				errs = append(errs, validate(fmt.Sprintf("%s[%d]", path, i), items, elem)...)
+			}
+		}
+	}
+	return errs
+}
+
+// join appends a property name to the path.
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// matchesType reports whether v has the type, or one of the types.
+func matchesType(t any, v any) bool {
+	for _, name := range stringValues(t) {
+		switch name {
+		case "integer":
+			if f, ok := number(v); ok && f == float64(int64(f)) {
+				return true
+			}
+		case "number":
+			if _, ok := number(v); ok {
+				return true
+			}
+		default:
+			if jsonType(v) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonType returns the JSON schema type name of the value.
+func jsonType(v any) string {
+	if _, ok := number(v); ok {
+		return "number"
+	}
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// number converts a numeric value to a float64.
+func number(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// equal compares JSON values. Numbers are equal if they have the same
+// value, regardless of their Go types.
+func equal(a, b any) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// stringValues returns the strings in a string, []string, or []any.
+func stringValues(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		var ss []string
+		for _, elem := range v {
+			if s, ok := elem.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	default:
+		return nil
+	}
+}
+
+func typeNames(t any) string {
+	names := stringValues(t)
+	if len(names) == 1 {
+		return names[0]
+	}
+	return fmt.Sprintf("one of %v", names)
+}
+
+func formatValues(values []any) string {
+	s := ""
+	for i, v := range values {
+		if i > 0 {
+			s += ", "
+		}
+		s += formatValue(v)
+	}
+	return s
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprint(v)
+	}
+}
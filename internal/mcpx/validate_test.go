@@ -0,0 +1,118 @@
+package mcpx
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidateArguments(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		args   string
+		errs   []string
+	}{
+		{
+			name:   "valid",
+			schema: `{"type": "object", "properties": {"path": {"type": "string"}, "line": {"type": "integer"}}, "required": ["path"]}`,
+			args:   `{"path": "main.go", "line": 12}`,
+		},
+		{
+			name:   "wrong type",
+			schema: `{"type": "object", "properties": {"path": {"type": "string"}}}`,
+			args:   `{"path": 1}`,
+			errs:   []string{"path: expected string, got number"},
+		},
+		{
+			name:   "not an integer",
+			schema: `{"type": "object", "properties": {"line": {"type": "integer"}}}`,
+			args:   `{"line": 1.5}`,
+			errs:   []string{"line: expected integer, got number"},
+		},
+		{
+			name:   "multiple types",
+			schema: `{"type": "object", "properties": {"value": {"type": ["string", "null"]}}}`,
+			args:   `{"value": null}`,
+		},
+		{
+			name:   "missing required",
+			schema: `{"type": "object", "properties": {"path": {"type": "string"}}, "required": ["path"]}`,
+			args:   `{}`,
+			errs:   []string{"path: missing required property"},
+		},
+		{
+			name:   "enum",
+			schema: `{"type": "object", "properties": {"mode": {"type": "string", "enum": ["a", "b"]}}}`,
+			args:   `{"mode": "c"}`,
+			errs:   []string{`mode: must be one of "a", "b", got "c"`},
+		},
+		{
+			name:   "const",
+			schema: `{"type": "object", "properties": {"version": {"const": 2}}}`,
+			args:   `{"version": 3}`,
+			errs:   []string{"version: must be 2, got 3"},
+		},
+		{
+			name:   "unlisted arguments are allowed",
+			schema: `{"type": "object", "properties": {"path": {"type": "string"}}}`,
+			args:   `{"path": "main.go", "extra": true}`,
+		},
+		{
+			name:   "additional properties false",
+			schema: `{"type": "object", "properties": {"path": {"type": "string"}}, "additionalProperties": false}`,
+			args:   `{"path": "main.go", "extra": true}`,
+			errs:   []string{"extra: unknown property"},
+		},
+		{
+			name:   "additional properties schema",
+			schema: `{"type": "object", "additionalProperties": {"type": "string"}}`,
+			args:   `{"a": "x", "b": 1}`,
+			errs:   []string{"b: expected string, got number"},
+		},
+		{
+			name:   "nested",
+			schema: `{"type": "object", "properties": {"files": {"type": "array", "items": {"type": "object", "properties": {"path": {"type": "string"}}, "required": ["path"]}}}}`,
+			args:   `{"files": [{"path": "a"}, {}, {"path": false}]}`,
+			errs:   []string{"files[1].path: missing required property", "files[2].path: expected string, got boolean"},
+		},
+		{
+			name:   "all errors",
+			schema: `{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "boolean"}}, "required": ["c"]}`,
+			args:   `{"a": 1, "b": "x"}`,
+			errs:   []string{"a: expected string, got number", "b: expected boolean, got string", "c: missing required property"},
+		},
+		{
+			name:   "unknown keywords are ignored",
+			schema: `{"type": "object", "properties": {"name": {"type": "string", "minLength": 10}}}`,
+			args:   `{"name": "x"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema, args map[string]any
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.args), &args); err != nil {
+				t.Fatal(err)
+			}
+			err := ValidateArguments(schema, args)
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.errs, "\n") {
+				t.Fatalf("got errors %q, want %q", got, tt.errs)
+			}
+		})
+	}
+}
+
+func TestValidateArgumentsNil(t *testing.T) {
+	schema := map[string]any{"type": "object", "required": []any{"path"}}
+	err := ValidateArguments(schema, nil)
+	if err == nil || err.Error() != "path: missing required property" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
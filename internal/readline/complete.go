@@ -0,0 +1,70 @@
+package readline
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Completer returns the candidates for the word at the end of line.
+// The candidates replace the word, which is the text after the last space.
+type Completer func(line string) []string
+
+// CompletePath returns the files and directories which start with prefix.
+// Directories have a trailing slash.
+func CompletePath(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	entries, err := os.ReadDir(filepath.Join(".", dir))
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		// hidden files must be asked for explicitly
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if e.IsDir() {
+			name += "/"
+		}
+		paths = append(paths, dir+name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CompleteWords returns the words which start with prefix.
+func CompleteWords(prefix string, words []string) []string {
+	var matches []string
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			matches = append(matches, w)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// commonPrefix returns the longest prefix shared by all the strings.
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// don't split a multi-byte rune
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
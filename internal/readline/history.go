@@ -0,0 +1,127 @@
+package readline
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// history is a bounded list of entries which is persisted to a file.
+// It implements term.History, but the entries are added by the Editor
+// once a complete (possibly multi-line) input has been read.
+type history struct {
+	entries []string
+	size    int
+	file    *os.File
+}
+
+// openHistory reads the history file and opens it for appending.
+// The file is created if it doesn't exist.
+func openHistory(name string, size int) (*history, error) {
+	h := &history{size: size}
+	if name == "" {
+		return h, nil
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %w", err)
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.append(unescapeEntry(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	h.file = f
+	return h, nil
+}
+
+// Add is called by the terminal for every line it reads. It does nothing
+// because continuation lines should not be added individually.
+func (h *history) Add(entry string) {}
+
+func (h *history) Len() int {
+	return len(h.entries)
+}
+
+func (h *history) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+// add adds the entry to the history and appends it to the history file.
+// Multi-line entries are escaped so that they're stored as a single line.
+func (h *history) add(entry string) error {
+	if strings.TrimSpace(entry) == "" {
+		return nil
+	}
+	if n := len(h.entries); n > 0 && h.entries[n-1] == entry {
+		return nil
+	}
+	h.append(entry)
+	if h.file == nil {
+		return nil
+	}
+	if _, err := fmt.Fprintln(h.file, escapeEntry(entry)); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// escapeEntry escapes the backslashes and newlines in the entry.
+func escapeEntry(entry string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(entry)
+}
+
+// unescapeEntry reverses escapeEntry. Other backslashes are kept
+// so that entries written before they were escaped are unchanged.
+func unescapeEntry(line string) string {
+	if !strings.Contains(line, `\`) {
+		return line
+	}
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			switch line[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
+
+// close closes the history file.
+func (h *history) close() error {
+	if h.file == nil {
+		return nil
+	}
+	return h.file.Close()
+}
+
+func (h *history) append(entry string) {
+	h.entries = append(h.entries, entry)
+	if h.size > 0 && len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+}
+
+// search returns the index of the most recent entry older than start
+// which contains the query.
+func (h *history) search(query string, start int) (int, bool) {
+	for idx := start + 1; idx < h.Len(); idx++ {
+		if strings.Contains(h.At(idx), query) {
+			return idx, true
+		}
+	}
+	return 0, false
+}
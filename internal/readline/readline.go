@@ -0,0 +1,252 @@
+// Package readline implements a line editor for the REPL.
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+type Options struct {
+	// Prompt is shown before the first line of input.
+	Prompt string
+	// ContinuationPrompt is shown before every other line of input.
+	// Defaults to "... ".
+	ContinuationPrompt string
+	// HistoryFile is where the history is persisted.
+	// The history is not persisted when it's empty.
+	HistoryFile string
+	// HistorySize is the maximum number of history entries.
+	// Defaults to 1000.
+	HistorySize int
+	// Complete provides the candidates for tab completion.
+	Complete Completer
+}
+
+// Editor reads input from stdin. When stdin is a terminal, it supports
+// line editing, history (including Ctrl-R search), tab completion, and
+// multi-line input. Lines ending with a backslash are continued on the
+// next line, and pasted text is read as a single input.
+type Editor struct {
+	mu           sync.Mutex
+	in           *os.File
+	out          io.Writer
+	term         *term.Terminal
+	scanner      *bufio.Scanner
+	history      *history
+	prompt       string
+	continuation string
+	complete     Completer
+	search       struct {
+		query string
+		idx   int
+		match string
+	}
+}
+
+func New(opt *Options) (*Editor, error) {
+	if opt == nil {
+		opt = &Options{}
+	}
+	if opt.ContinuationPrompt == "" {
+		opt.ContinuationPrompt = "... "
+	}
+	if opt.HistorySize <= 0 {
+		opt.HistorySize = 1000
+	}
+	h, err := openHistory(opt.HistoryFile, opt.HistorySize)
+	if err != nil {
+		return nil, err
+	}
+	e := &Editor{
+		in:           os.Stdin,
+		out:          os.Stdout,
+		history:      h,
+		prompt:       opt.Prompt,
+		continuation: opt.ContinuationPrompt,
+		complete:     opt.Complete,
+	}
+	if term.IsTerminal(int(e.in.Fd())) {
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{ctrlCReader{e.in}, e.out}
+		e.term = term.NewTerminal(rw, e.prompt)
+		e.term.History = h
+		e.term.AutoCompleteCallback = e.autoComplete
+	} else {
+		e.scanner = bufio.NewScanner(e.in)
+	}
+	return e, nil
+}
+
+// IsTerminal reports whether the input is read from a terminal.
+func (e *Editor) IsTerminal() bool {
+	return e.term != nil
+}
+
+// ReadLine reads the next input. It returns io.EOF when there's
+// no more input or Ctrl-D is pressed on an empty line.
+func (e *Editor) ReadLine() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	text, err := e.read(e.prompt)
+	if err != nil {
+		return "", err
+	}
+	// failing to persist the history shouldn't lose the input
+	_ = e.history.add(text)
+	return text, nil
+}
+
+// Prompt reads an answer to a question using a different prompt.
+// The answer isn't added to the history.
+func (e *Editor) Prompt(prompt string) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.read(prompt)
+}
+
+func (e *Editor) read(prompt string) (string, error) {
+	if e.term == nil {
+		return e.readLines(prompt)
+	}
+	fd := int(e.in.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to set terminal to raw mode: %w", err)
+	}
+	defer term.Restore(fd, state)
+	if width, height, err := term.GetSize(fd); err == nil && width > 0 {
+		e.term.SetSize(width, height)
+	}
+	e.term.SetBracketedPasteMode(true)
+	defer e.term.SetBracketedPasteMode(false)
+	e.term.SetPrompt(prompt)
+	var lines []string
+	for {
+		line, err := e.term.ReadLine()
+		if err == term.ErrPasteIndicator {
+			lines = append(lines, line)
+			e.term.SetPrompt(e.continuation)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if line, ok := strings.CutSuffix(line, `\`); ok {
+			lines = append(lines, line)
+			e.term.SetPrompt(e.continuation)
+			continue
+		}
+		lines = append(lines, line)
+		break
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n"), nil
+}
+
+// readLines reads input when stdin isn't a terminal.
+func (e *Editor) readLines(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	var lines []string
+	for e.scanner.Scan() {
+		line, ok := strings.CutSuffix(e.scanner.Text(), `\`)
+		lines = append(lines, line)
+		if !ok {
+			return strings.Join(lines, "\n"), nil
+		}
+		fmt.Fprint(e.out, e.continuation)
+	}
+	if err := e.scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(lines) > 0 {
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", io.EOF
+}
+
+// Close closes the history file.
+func (e *Editor) Close() error {
+	return e.history.close()
+}
+
+const (
+	keyTab   = '\t'
+	keyCtrlR = 18
+)
+
+// autoComplete handles tab completion and Ctrl-R history search.
+func (e *Editor) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	switch key {
+	case keyTab:
+		return e.completeWord(line, pos)
+	case keyCtrlR:
+		// pressing Ctrl-R again continues the search from the last match
+		if e.search.match == "" || line != e.search.match {
+			e.search.query = line
+			e.search.idx = -1
+		}
+		idx, ok := e.history.search(e.search.query, e.search.idx)
+		if !ok {
+			return line, pos, true
+		}
+		e.search.idx = idx
+		e.search.match = e.history.At(idx)
+		return e.search.match, len(e.search.match), true
+	default:
+		e.search.match = ""
+		return "", 0, false
+	}
+}
+
+// completeWord completes the word before the cursor. If there are multiple
+// candidates, the word is extended to their common prefix or they're printed.
+func (e *Editor) completeWord(line string, pos int) (string, int, bool) {
+	if e.complete == nil {
+		return "", 0, false
+	}
+	prefix := line[:pos]
+	start := strings.LastIndex(prefix, " ") + 1
+	word := prefix[start:]
+	candidates := e.complete(prefix)
+	var completed string
+	switch len(candidates) {
+	case 0:
+		return "", 0, false
+	case 1:
+		completed = candidates[0]
+		if !strings.HasSuffix(completed, "/") {
+			completed += " "
+		}
+	default:
+		completed = commonPrefix(candidates)
+		if completed == word {
+			var b bytes.Buffer
+			b.WriteString(strings.Join(candidates, "  "))
+			b.WriteString("\n")
+			e.term.Write(b.Bytes())
+			return "", 0, false
+		}
+	}
+	return prefix[:start] + completed + line[pos:], start + len(completed), true
+}
+
+// ctrlCReader turns Ctrl-C into Ctrl-E Ctrl-U so that it clears the line
+// instead of being reported as io.EOF by the terminal.
+type ctrlCReader struct {
+	r io.Reader
+}
+
+func (r ctrlCReader) Read(p []byte) (int, error) {
+	// leave space for every byte to be expanded
+	n, err := r.r.Read(p[:(len(p)+1)/2])
+	data := bytes.ReplaceAll(p[:n], []byte{3}, []byte{5, 21})
+	return copy(p, data), err
+}
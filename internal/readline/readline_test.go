@@ -0,0 +1,312 @@
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// newTestEditor returns an editor which reads the input as if
+// stdin weren't a terminal.
+func newTestEditor(input string) (*Editor, *bytes.Buffer) {
+	var out bytes.Buffer
+	h, _ := openHistory("", 10)
+	e := &Editor{
+		out:          &out,
+		scanner:      bufio.NewScanner(strings.NewReader(input)),
+		history:      h,
+		prompt:       "> ",
+		continuation: "... ",
+	}
+	return e, &out
+}
+
+func TestEditorReadLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		lines  []string
+		output string
+	}{
+		{name: "empty", input: "", output: "> "},
+		{name: "lines", input: "a\nb\n", lines: []string{"a", "b"}, output: "> > > "},
+		{name: "no trailing newline", input: "a", lines: []string{"a"}, output: "> > "},
+		{name: "continuation", input: "a\\\nb\nc\n", lines: []string{"a\nb", "c"}, output: "> ... > > "},
+		{name: "continuation at eof", input: "a\\\n", lines: []string{"a"}, output: "> ... > "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, out := newTestEditor(tt.input)
+			var lines []string
+			for {
+				line, err := e.ReadLine()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines = append(lines, line)
+			}
+			if !slices.Equal(lines, tt.lines) {
+				t.Fatalf("got lines %q, want %q", lines, tt.lines)
+			}
+			if out.String() != tt.output {
+				t.Fatalf("got output %q, want %q", out.String(), tt.output)
+			}
+		})
+	}
+}
+
+func TestEditorPrompt(t *testing.T) {
+	e, out := newTestEditor("y\nnext\n")
+	answer, err := e.Prompt("Allow? ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer != "y" {
+		t.Fatalf("got answer %q", answer)
+	}
+	if out.String() != "Allow? " {
+		t.Fatalf("got output %q", out.String())
+	}
+	if e.history.Len() != 0 {
+		t.Fatal("the answer was added to the history")
+	}
+	if line, _ := e.ReadLine(); line != "next" {
+		t.Fatalf("got line %q", line)
+	}
+}
+
+func TestHistoryAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		add     []string
+		size    int
+		entries []string
+	}{
+		{name: "empty", add: []string{"", "  "}, size: 10},
+		{name: "order", add: []string{"a", "b"}, size: 10, entries: []string{"a", "b"}},
+		{name: "duplicates", add: []string{"a", "a", "b", "a"}, size: 10, entries: []string{"a", "b", "a"}},
+		{name: "multi-line", add: []string{"a\n  b\nc"}, size: 10, entries: []string{"a\n  b\nc"}},
+		{name: "size", add: []string{"a", "b", "c"}, size: 2, entries: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := openHistory("", tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range tt.add {
+				if err := h.add(entry); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !slices.Equal(h.entries, tt.entries) {
+				t.Fatalf("got %q, want %q", h.entries, tt.entries)
+			}
+		})
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(name, []byte("a\nb\nc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	h, err := openHistory(name, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(h.entries, []string{"b", "c"}) {
+		t.Fatalf("got %q", h.entries)
+	}
+	entries := []string{"d\n\tindented", `C:\path\n`}
+	for _, entry := range entries {
+		if err := h.add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := h.close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nb\nc\nd\\n\tindented\nC:\\\\path\\\\n\n"; string(data) != want {
+		t.Fatalf("got file %q, want %q", data, want)
+	}
+	// the entries are unchanged when the file is read again
+	h, err = openHistory(name, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.close()
+	if want := append([]string{"a", "b", "c"}, entries...); !slices.Equal(h.entries, want) {
+		t.Fatalf("got %q, want %q", h.entries, want)
+	}
+}
+
+func TestUnescapeEntry(t *testing.T) {
+	tests := []struct {
+		line  string
+		entry string
+	}{
+		{line: "plain", entry: "plain"},
+		{line: `a\nb`, entry: "a\nb"},
+		{line: `a\\nb`, entry: `a\nb`},
+		// entries written before escaping keep their backslashes
+		{line: `C:\path`, entry: `C:\path`},
+		{line: `trailing\`, entry: `trailing\`},
+	}
+	for _, tt := range tests {
+		if got := unescapeEntry(tt.line); got != tt.entry {
+			t.Fatalf("unescapeEntry(%q): got %q, want %q", tt.line, got, tt.entry)
+		}
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	h, _ := openHistory("", 10)
+	for _, entry := range []string{"go test", "ls", "go build", "pwd"} {
+		h.add(entry)
+	}
+	tests := []struct {
+		query string
+		start int
+		entry string
+		ok    bool
+	}{
+		{query: "go", start: -1, entry: "go build", ok: true},
+		{query: "go", start: 1, entry: "go test", ok: true},
+		{query: "go", start: 3, ok: false},
+		{query: "missing", start: -1, ok: false},
+		{query: "", start: -1, entry: "pwd", ok: true},
+	}
+	for _, tt := range tests {
+		idx, ok := h.search(tt.query, tt.start)
+		if ok != tt.ok {
+			t.Fatalf("search(%q, %d): got ok %v, want %v", tt.query, tt.start, ok, tt.ok)
+		}
+		if ok && h.At(idx) != tt.entry {
+			t.Fatalf("search(%q, %d): got %q, want %q", tt.query, tt.start, h.At(idx), tt.entry)
+		}
+	}
+}
+
+func TestCompleteWords(t *testing.T) {
+	words := []string{"/help", "/mcp", "/exit", "/history"}
+	tests := []struct {
+		prefix  string
+		matches []string
+	}{
+		{prefix: "/h", matches: []string{"/help", "/history"}},
+		{prefix: "/m", matches: []string{"/mcp"}},
+		{prefix: "/x"},
+		{prefix: "", matches: []string{"/exit", "/help", "/history", "/mcp"}},
+	}
+	for _, tt := range tests {
+		if got := CompleteWords(tt.prefix, words); !slices.Equal(got, tt.matches) {
+			t.Errorf("CompleteWords(%q) = %q, want %q", tt.prefix, got, tt.matches)
+		}
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"main.go", "main_test.go", ".env", "internal/x.go"} {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	tests := []struct {
+		prefix string
+		paths  []string
+	}{
+		{prefix: "", paths: []string{"internal/", "main.go", "main_test.go"}},
+		{prefix: "main", paths: []string{"main.go", "main_test.go"}},
+		{prefix: ".", paths: []string{".env"}},
+		{prefix: "internal/", paths: []string{"internal/x.go"}},
+		{prefix: "missing/"},
+	}
+	for _, tt := range tests {
+		if got := CompletePath(tt.prefix); !slices.Equal(got, tt.paths) {
+			t.Errorf("CompletePath(%q) = %q, want %q", tt.prefix, got, tt.paths)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		in     []string
+		prefix string
+	}{
+		{in: nil, prefix: ""},
+		{in: []string{"abc"}, prefix: "abc"},
+		{in: []string{"abc", "abd"}, prefix: "ab"},
+		{in: []string{"abc", "xyz"}, prefix: ""},
+		{in: []string{"héllo", "hëllo"}, prefix: "h"},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.in); got != tt.prefix {
+			t.Errorf("commonPrefix(%q) = %q, want %q", tt.in, got, tt.prefix)
+		}
+	}
+}
+
+func TestCompleteWord(t *testing.T) {
+	e := &Editor{
+		complete: func(line string) []string {
+			words := strings.Fields(line)
+			if len(words) == 0 || strings.HasSuffix(line, " ") {
+				return nil
+			}
+			return CompleteWords(words[len(words)-1], []string{"/hello", "/help", "/history", "/mcp", "main/"})
+		},
+	}
+	tests := []struct {
+		name string
+		line string
+		pos  int
+		out  string
+		npos int
+		ok   bool
+	}{
+		{name: "single", line: "/m", pos: 2, out: "/mcp ", npos: 5, ok: true},
+		{name: "directory", line: "ma", pos: 2, out: "main/", npos: 5, ok: true},
+		{name: "common prefix", line: "/he", pos: 3, out: "/hel", npos: 4, ok: true},
+		{name: "after cursor", line: "x /m y", pos: 4, out: "x /mcp  y", npos: 7, ok: true},
+		{name: "none", line: "/x", pos: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, npos, ok := e.completeWord(tt.line, tt.pos)
+			if ok != tt.ok || out != tt.out || npos != tt.npos {
+				t.Fatalf("got (%q, %d, %v), want (%q, %d, %v)", out, npos, ok, tt.out, tt.npos, tt.ok)
+			}
+		})
+	}
+}
+
+func TestCtrlCReader(t *testing.T) {
+	r := ctrlCReader{strings.NewReader("ab\x03c")}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "ab\x05\x15c" {
+		t.Fatalf("got %q", data)
+	}
+}
@@ -2,27 +2,31 @@ package sloppy
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"os"
+	"path/filepath"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/shared/constant"
-	"github.com/icholy/sloppy/internal/termcolor"
+	"github.com/icholy/sloppy/internal/mcpx"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
+// DefaultModel is the model used when one isn't specified.
+const DefaultModel = string(anthropic.ModelClaudeSonnet4_20250514)
+
 type AnthropicAgentOptions struct {
 	Name   string
 	Client *anthropic.Client
-	Output io.Writer
+	// Model defaults to DefaultModel.
+	Model string
 }
 
 type AnthropicAgent struct {
 	name     string
 	client   *anthropic.Client
-	output   io.Writer
+	model    string
 	messages []anthropic.MessageParam
 	pending  []anthropic.ContentBlockUnion
 }
@@ -38,28 +42,38 @@ func NewAnthropicAgent(opt *AnthropicAgentOptions) *AnthropicAgent {
 		client := anthropic.NewClient()
 		opt.Client = &client
 	}
-	if opt.Output == nil {
-		opt.Output = os.Stdout
+	if opt.Model == "" {
+		opt.Model = DefaultModel
 	}
 	return &AnthropicAgent{
 		name:   opt.Name,
 		client: opt.Client,
-		output: opt.Output,
+		model:  opt.Model,
 	}
 }
 
 func (a *AnthropicAgent) Run(ctx context.Context, input *RunInput) (*RunOutput, error) {
-	if input.Prompt != "" {
-		a.append(anthropic.NewUserMessage(anthropic.NewTextBlock(input.Prompt)))
+	for _, m := range input.Messages {
+		a.append(a.fromPrompt(m))
+	}
+	if input.Prompt != "" || len(input.Attachments) > 0 {
+		var blocks []anthropic.ContentBlockParamUnion
+		for _, att := range input.Attachments {
+			blocks = append(blocks, attachmentBlock(att))
+		}
+		if input.Prompt != "" {
+			blocks = append(blocks, anthropic.NewTextBlock(input.Prompt))
+		}
+		a.append(anthropic.NewUserMessage(blocks...))
 	}
 	if res := input.CallToolResult; res != nil {
 		toolUseID, ok := input.Meta["toolUseID"].(string)
 		if !ok {
 			return nil, fmt.Errorf("missing toolUseId in metadata")
 		}
-		results := a.toAnthropic(toolUseID, res)
-		a.append(anthropic.NewUserMessage(results...))
+		a.append(anthropic.NewUserMessage(a.toAnthropic(toolUseID, res)))
 	}
+	output := &RunOutput{}
 	if len(a.pending) == 0 {
 		response, err := a.llm(ctx, input.Tools)
 		if err != nil {
@@ -67,25 +81,28 @@ func (a *AnthropicAgent) Run(ctx context.Context, input *RunInput) (*RunOutput,
 		}
 		a.append(response.ToParam())
 		a.pending = response.Content
+		output.Usage = &Usage{
+			InputTokens:  response.Usage.InputTokens,
+			OutputTokens: response.Usage.OutputTokens,
+		}
 	}
 	for len(a.pending) > 0 {
 		block := a.pending[0]
 		a.pending = a.pending[1:]
 		switch block.Type {
 		case "text":
-			fmt.Fprintf(a.output, "%s: %s\n", termcolor.Text(a.name, termcolor.Yellow), block.Text)
+			output.Text = append(output.Text, block.Text)
 		case "tool_use":
 			req, err := a.toMCP(block)
 			if err != nil {
 				return nil, err
 			}
-			return &RunOutput{
-				CallToolRequest: req,
-				Meta:            map[string]any{"toolUseID": block.ID},
-			}, nil
+			output.CallToolRequest = req
+			output.Meta = map[string]any{"toolUseID": block.ID}
+			return output, nil
 		}
 	}
-	return &RunOutput{}, nil
+	return output, nil
 }
 
 func (a *AnthropicAgent) LastMessage() string {
@@ -97,17 +114,77 @@ func (a *AnthropicAgent) LastMessage() string {
 	return string(data)
 }
 
-func (a *AnthropicAgent) toAnthropic(toolUseID string, res *mcp.CallToolResult) []anthropic.ContentBlockParamUnion {
-	var results []anthropic.ContentBlockParamUnion
+// toAnthropic converts the tool result into a single tool_result block
+// which contains all of the result's content.
+func (a *AnthropicAgent) toAnthropic(toolUseID string, res *mcp.CallToolResult) anthropic.ContentBlockParamUnion {
+	block := anthropic.ToolResultBlockParam{
+		ToolUseID: toolUseID,
+		IsError:   anthropic.Bool(res.IsError),
+	}
 	for _, c := range res.Content {
-		if text, ok := c.(mcp.TextContent); ok {
-			results = append(results, anthropic.NewToolResultBlock(toolUseID, text.Text, res.IsError))
-		} else {
-			// TODO: figure out what to do here
-			results = append(results, anthropic.NewToolResultBlock(toolUseID, "unsupported response type", true))
+		block.Content = append(block.Content, toolResultContent(c))
+	}
+	return anthropic.ContentBlockParamUnion{OfToolResult: &block}
+}
+
+func toolResultContent(c mcp.Content) anthropic.ToolResultBlockParamContentUnion {
+	text, image := contentBlock(c)
+	return anthropic.ToolResultBlockParamContentUnion{OfText: text, OfImage: image}
+}
+
+// contentBlock converts MCP content into either a text or an image block.
+// Images which the model doesn't support and non-image resources are
+// converted to text.
+func contentBlock(c mcp.Content) (*anthropic.TextBlockParam, *anthropic.ImageBlockParam) {
+	text := func(s string) (*anthropic.TextBlockParam, *anthropic.ImageBlockParam) {
+		return &anthropic.TextBlockParam{Text: s}, nil
+	}
+	image := func(mimeType, data string) (*anthropic.TextBlockParam, *anthropic.ImageBlockParam) {
+		return nil, &anthropic.ImageBlockParam{
+			Source: anthropic.ImageBlockParamSourceUnion{
+				OfBase64: &anthropic.Base64ImageSourceParam{
+					Data:      data,
+					MediaType: anthropic.Base64ImageSourceMediaType(mimeType),
+				},
+			},
+		}
+	}
+	switch c := c.(type) {
+	case mcp.TextContent:
+		return text(c.Text)
+	case mcp.ImageContent:
+		if !mcpx.IsSupportedImage(c.MIMEType) {
+			return text(fmt.Sprintf("unsupported image type: %s", c.MIMEType))
+		}
+		return image(c.MIMEType, c.Data)
+	case mcp.EmbeddedResource:
+		if blob, ok := c.Resource.(mcp.BlobResourceContents); ok && mcpx.IsSupportedImage(blob.MIMEType) {
+			return image(blob.MIMEType, blob.Blob)
 		}
+		return text(FormatResourceContents("", []mcp.ResourceContents{c.Resource}))
+	default:
+		return text(fmt.Sprintf("unsupported content type: %T", c))
+	}
+}
+
+// attachmentBlock converts an attachment into an image or document block.
+func attachmentBlock(att Attachment) anthropic.ContentBlockParamUnion {
+	data := base64.StdEncoding.EncodeToString(att.Data)
+	if mcpx.IsSupportedImage(att.MIMEType) {
+		return anthropic.NewImageBlockBase64(att.MIMEType, data)
+	}
+	block := anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: data})
+	block.OfDocument.Title = anthropic.String(filepath.Base(att.Name))
+	return block
+}
+
+func (a *AnthropicAgent) fromPrompt(m mcp.PromptMessage) anthropic.MessageParam {
+	text, image := contentBlock(m.Content)
+	block := anthropic.ContentBlockParamUnion{OfText: text, OfImage: image}
+	if m.Role == mcp.RoleAssistant {
+		return anthropic.NewAssistantMessage(block)
 	}
-	return results
+	return anthropic.NewUserMessage(block)
 }
 
 func (a *AnthropicAgent) toMCP(block anthropic.ContentBlockUnion) (*mcp.CallToolRequest, error) {
@@ -121,7 +198,7 @@ func (a *AnthropicAgent) toMCP(block anthropic.ContentBlockUnion) (*mcp.CallTool
 
 func (a *AnthropicAgent) llm(ctx context.Context, tools []mcp.Tool) (*anthropic.Message, error) {
 	params := anthropic.MessageNewParams{
-		Model:     anthropic.ModelClaudeSonnet4_20250514,
+		Model:     anthropic.Model(a.model),
 		MaxTokens: 1024,
 		Messages:  a.messages,
 	}
@@ -0,0 +1,127 @@
+package sloppy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newFakeClient returns a client for a fake Anthropic API which answers the
+// message requests with each of the contents in turn. The last content is
+// repeated. The request bodies are sent on the returned channel.
+func newFakeClient(t *testing.T, contents ...[]any) (*anthropic.Client, <-chan map[string]any) {
+	t.Helper()
+	requests := make(chan map[string]any, 10)
+	var mu sync.Mutex
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requests <- body
+		mu.Lock()
+		content := contents[min(n, len(contents)-1)]
+		n++
+		mu.Unlock()
+		stop := "end_turn"
+		for _, c := range content {
+			if c.(map[string]any)["type"] == "tool_use" {
+				stop = "tool_use"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       body["model"],
+			"stop_reason": stop,
+			"content":     content,
+			"usage":       map[string]any{"input_tokens": 1, "output_tokens": 1},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	client := anthropic.NewClient(option.WithBaseURL(ts.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	return &client, requests
+}
+
+func TestAnthropicAgentToolResult(t *testing.T) {
+	client, requests := newFakeClient(t,
+		[]any{map[string]any{"type": "tool_use", "id": "toolu_1", "name": "screenshot", "input": map[string]any{}}},
+		[]any{map[string]any{"type": "text", "text": "done"}},
+	)
+	agent := NewAnthropicAgent(&AnthropicAgentOptions{Client: client})
+	out, err := agent.Run(context.Background(), &RunInput{Prompt: "take a screenshot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.CallToolRequest == nil {
+		t.Fatal("expected a tool call")
+	}
+	<-requests
+	_, err = agent.Run(context.Background(), &RunInput{
+		CallToolResult: &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.NewTextContent("the screen"),
+				mcp.NewImageContent("aW1hZ2U=", "image/png"),
+				mcp.NewImageContent("aW1hZ2U=", "image/bmp"),
+			},
+		},
+		Meta: out.Meta,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := <-requests
+	data, err := json.Marshal(body["messages"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type      string `json:"type"`
+			ToolUseID string `json:"tool_use_id"`
+			Content   []struct {
+				Type   string `json:"type"`
+				Text   string `json:"text"`
+				Source struct {
+					MediaType string `json:"media_type"`
+				} `json:"source"`
+			} `json:"content"`
+		} `json:"content"`
+	}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("got %d messages, want 3", len(messages))
+	}
+	last := messages[2]
+	if last.Role != "user" || len(last.Content) != 1 {
+		t.Fatalf("want a single tool_result block: %s", data)
+	}
+	block := last.Content[0]
+	if block.Type != "tool_result" || block.ToolUseID != "toolu_1" || len(block.Content) != 3 {
+		t.Fatalf("unexpected tool_result block: %s", data)
+	}
+	text, image, unsupported := block.Content[0], block.Content[1], block.Content[2]
+	if text.Type != "text" || text.Text != "the screen" {
+		t.Fatalf("unexpected text content: %+v", text)
+	}
+	if image.Type != "image" || image.Source.MediaType != "image/png" {
+		t.Fatalf("unexpected image content: %+v", image)
+	}
+	if unsupported.Type != "text" || unsupported.Text != "unsupported image type: image/bmp" {
+		t.Fatalf("unexpected unsupported content: %+v", unsupported)
+	}
+}
@@ -0,0 +1,75 @@
+package sloppy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+)
+
+// MaxPDFSize is the largest PDF accepted by the model. The request limit
+// is 32MB and PDFs are sent base64 encoded, which adds a third.
+// Images are limited to mcpx.MaxImageSize.
+const MaxPDFSize = 24 << 20
+
+// Attachment is a file which is sent to the model along with a prompt.
+type Attachment struct {
+	Name     string
+	MIMEType string
+	Data     []byte
+}
+
+// ReadAttachment reads an image or PDF file. The type is detected from the
+// file contents and an error is returned if it's not supported or the file
+// is too large.
+func ReadAttachment(name string) (*Attachment, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("invalid attachment: %s: not a regular file", name)
+	}
+	if info.Size() > MaxPDFSize {
+		return nil, fmt.Errorf("attachment too large: %s: %d bytes (max %d)", name, info.Size(), MaxPDFSize)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attachment: %w", err)
+	}
+	mimeType := http.DetectContentType(data)
+	switch {
+	case mcpx.IsSupportedImage(mimeType):
+		if len(data) > mcpx.MaxImageSize {
+			return nil, fmt.Errorf("image too large: %s: %d bytes (max %d)", name, len(data), mcpx.MaxImageSize)
+		}
+	case mimeType == "application/pdf":
+	default:
+		return nil, fmt.Errorf("unsupported attachment type: %s: %s", name, mimeType)
+	}
+	return &Attachment{
+		Name:     name,
+		MIMEType: mimeType,
+		Data:     data,
+	}, nil
+}
+
+// attachmentRe matches @path mentions.
+var attachmentRe = regexp.MustCompile(`(^|\s)@(\S+)`)
+
+// AttachmentMentions returns the paths of every @path mention in the prompt
+// which refers to an image or PDF file by its extension.
+func AttachmentMentions(prompt string) []string {
+	var paths []string
+	for _, match := range attachmentRe.FindAllStringSubmatch(prompt, -1) {
+		switch strings.ToLower(filepath.Ext(match[2])) {
+		case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".pdf":
+			paths = append(paths, match[2])
+		}
+	}
+	return paths
+}
@@ -0,0 +1,130 @@
+package sloppy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+)
+
+func TestReadAttachment(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + "image"
+	pdf := "%PDF-1.7\n" + "document"
+	tests := []struct {
+		name string
+		// setup creates the attachment and returns its path
+		setup    func(t *testing.T, dir string) string
+		mimeType string
+		err      string
+	}{
+		{
+			name: "png",
+			setup: func(t *testing.T, dir string) string {
+				return writeAttachment(t, dir, "image.png", png)
+			},
+			mimeType: "image/png",
+		},
+		{
+			name: "pdf",
+			setup: func(t *testing.T, dir string) string {
+				return writeAttachment(t, dir, "doc.pdf", pdf)
+			},
+			mimeType: "application/pdf",
+		},
+		{
+			name: "type from contents",
+			setup: func(t *testing.T, dir string) string {
+				return writeAttachment(t, dir, "image.pdf", png)
+			},
+			mimeType: "image/png",
+		},
+		{
+			name: "unsupported",
+			setup: func(t *testing.T, dir string) string {
+				return writeAttachment(t, dir, "notes.png", "hello world\n")
+			},
+			err: "unsupported attachment type",
+		},
+		{
+			name: "image too large",
+			setup: func(t *testing.T, dir string) string {
+				return writeAttachment(t, dir, "big.png", png+strings.Repeat("x", mcpx.MaxImageSize))
+			},
+			err: "image too large",
+		},
+		{
+			name: "pdf too large",
+			setup: func(t *testing.T, dir string) string {
+				name := writeAttachment(t, dir, "big.pdf", pdf)
+				if err := os.Truncate(name, MaxPDFSize+1); err != nil {
+					t.Fatal(err)
+				}
+				return name
+			},
+			err: "attachment too large",
+		},
+		{
+			name: "directory",
+			setup: func(t *testing.T, dir string) string {
+				return dir
+			},
+			err: "not a regular file",
+		},
+		{
+			name: "missing",
+			setup: func(t *testing.T, dir string) string {
+				return filepath.Join(dir, "missing.png")
+			},
+			err: "failed to read attachment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := tt.setup(t, t.TempDir())
+			att, err := ReadAttachment(name)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if att.Name != name || att.MIMEType != tt.mimeType || len(att.Data) == 0 {
+				t.Fatalf("unexpected attachment: %s %s %d bytes", att.Name, att.MIMEType, len(att.Data))
+			}
+		})
+	}
+}
+
+func TestAttachmentMentions(t *testing.T) {
+	tests := []struct {
+		prompt string
+		paths  []string
+	}{
+		{prompt: "describe @screenshot.png", paths: []string{"screenshot.png"}},
+		{prompt: "@a.JPG and @docs/b.pdf", paths: []string{"a.JPG", "docs/b.pdf"}},
+		{prompt: "compare @a.jpeg\n@b.gif\t@c.webp", paths: []string{"a.jpeg", "b.gif", "c.webp"}},
+		{prompt: "look at @main.go", paths: nil},
+		{prompt: "email me@example.png", paths: nil},
+		{prompt: "no mentions", paths: nil},
+	}
+	for _, tt := range tests {
+		if got := AttachmentMentions(tt.prompt); !slices.Equal(got, tt.paths) {
+			t.Errorf("AttachmentMentions(%q) = %q, want %q", tt.prompt, got, tt.paths)
+		}
+	}
+}
+
+func writeAttachment(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	name = filepath.Join(dir, name)
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
@@ -1,11 +1,11 @@
 package sloppy
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/icholy/sloppy/internal/mcpx"
 	"github.com/mark3labs/mcp-go/mcp"
@@ -17,14 +17,22 @@ type Agent interface {
 }
 
 type RunInput struct {
-	Meta           map[string]any
-	Prompt         string
+	Meta map[string]any
+	// Messages are added to the conversation before the Prompt.
+	Messages []mcp.PromptMessage
+	Prompt   string
+	// Attachments are sent along with the Prompt.
+	Attachments    []Attachment
 	CallToolResult *mcp.CallToolResult
 	Tools          []mcp.Tool
 }
 
 type RunOutput struct {
-	Meta            map[string]any
+	Meta map[string]any
+	// Text is the text written by the agent during this turn.
+	Text []string
+	// Usage is set if the agent made a model request during this turn.
+	Usage           *Usage
 	CallToolRequest *mcp.CallToolRequest
 }
 
@@ -38,32 +46,102 @@ type Driver struct {
 	Tools    []Tool
 	Stack    []Frame
 	NewAgent func(name string) Agent
+	// Servers, when set, replaces Tools with the tools from the
+	// running servers before every turn.
+	Servers *ServerManager
+	// Sink receives the events emitted while running the loop.
+	// Events are discarded when it's nil.
+	Sink EventSink
+	// MaxTurns limits the number of times the agents are run by a
+	// single call to Loop. There is no limit when it's zero.
+	MaxTurns int
+	// Hooks are run around prompts, tool calls, and the end of turns.
+	Hooks []Hook
+}
+
+// HookBlockedError is returned by the driver when a hook blocks a prompt.
+type HookBlockedError struct {
+	Message string
+}
+
+func (e *HookBlockedError) Error() string {
+	if e.Message == "" {
+		return "prompt blocked by hook"
+	}
+	return fmt.Sprintf("prompt blocked by hook: %s", e.Message)
+}
+
+// RootAgent is the name of the agent which the driver starts with.
+const RootAgent = "sloppy"
+
+// ErrMaxTurns is returned by the driver when MaxTurns is reached.
+var ErrMaxTurns = errors.New("maximum number of turns reached")
+
+// ToolError is returned by the driver when a tool call fails without
+// producing a result: a transport or protocol error, or a failed hook.
+// Tool results with IsError set are sent back to the model instead.
+type ToolError struct {
+	Tool string
+	Err  error
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("failed to call tool: %s: %v", e.Tool, e.Err)
+}
+
+func (e *ToolError) Unwrap() error {
+	return e.Err
 }
 
 func (d *Driver) Loop(ctx context.Context, prompt string) error {
+	return d.LoopInput(ctx, &RunInput{Prompt: prompt})
+}
+
+// LoopInput is like Loop, but starts with the provided input.
+func (d *Driver) LoopInput(ctx context.Context, input *RunInput) error {
 	if len(d.Stack) == 0 {
 		d.Stack = append(d.Stack, Frame{
-			Name:  "sloppy",
+			Name:  RootAgent,
 			Agent: d.NewAgent(""),
 		})
 	}
-	input := &RunInput{Prompt: prompt}
-	for {
+	if input.Prompt != "" || len(input.Messages) > 0 {
+		if err := d.submit(ctx, input); err != nil {
+			d.emit(Event{Type: EventError, Agent: d.Stack[0].Name, Text: err.Error()})
+			return err
+		}
+	}
+	var continued bool
+	for turn := 1; ; turn++ {
 		frame := d.Stack[len(d.Stack)-1]
 		agent := frame.Agent
+		if d.MaxTurns > 0 && turn > d.MaxTurns {
+			d.emit(Event{Type: EventError, Agent: frame.Name, Text: ErrMaxTurns.Error()})
+			return ErrMaxTurns
+		}
+		if d.Servers != nil {
+			d.Tools = d.Servers.Tools()
+		}
 		input.Tools = d.tools()
+		d.emit(Event{Type: EventTurnStart, Agent: frame.Name})
 		output, err := agent.Run(ctx, input)
 		if err != nil {
+			d.emit(Event{Type: EventError, Agent: frame.Name, Text: err.Error()})
 			return err
 		}
+		if output.Usage != nil {
+			d.emit(Event{Type: EventUsage, Agent: frame.Name, Usage: output.Usage})
+		}
+		for _, text := range output.Text {
+			d.emit(Event{Type: EventText, Agent: frame.Name, Text: text})
+		}
 		if req := output.CallToolRequest; req != nil {
-			var buf bytes.Buffer
-			encoder := json.NewEncoder(&buf)
-			encoder.SetEscapeHTML(false)
-			encoder.SetIndent("", "  ")
-			encoder.Encode(req.Params.Arguments)
-			data := strings.TrimSpace(buf.String())
-			fmt.Printf("tool: %s %s\n", req.Params.Name, data)
+			d.emit(Event{
+				Type:      EventToolCall,
+				Agent:     frame.Name,
+				Tool:      req.Params.Name,
+				Arguments: req.Params.Arguments,
+			})
 
 			// we special case the run_agent tool
 			if req.Params.Name == "run_agent" {
@@ -83,6 +161,7 @@ func (d *Driver) Loop(ctx context.Context, prompt string) error {
 					Meta:  output.Meta,
 					Agent: d.NewAgent(args.Name),
 				})
+				d.emit(Event{Type: EventAgentStart, Agent: args.Name, Text: args.Prompt})
 				input = &RunInput{
 					Meta: output.Meta,
 					Prompt: strings.Join([]string{
@@ -94,10 +173,18 @@ func (d *Driver) Loop(ctx context.Context, prompt string) error {
 				continue
 			}
 
-			res, err := d.call(ctx, *req)
+			res, err := d.call(ctx, frame.Name, *req)
 			if err != nil {
+				err = &ToolError{Tool: req.Params.Name, Err: err}
+				d.emit(Event{Type: EventError, Agent: frame.Name, Tool: req.Params.Name, Text: err.Error()})
 				return err
 			}
+			d.emit(Event{
+				Type:   EventToolResult,
+				Agent:  frame.Name,
+				Tool:   req.Params.Name,
+				Result: res,
+			})
 			input = &RunInput{
 				CallToolResult: res,
 				Meta:           output.Meta,
@@ -107,24 +194,87 @@ func (d *Driver) Loop(ctx context.Context, prompt string) error {
 
 		// are we in a nested agent ?
 		if len(d.Stack) > 1 {
+			last := agent.LastMessage()
+			d.emit(Event{Type: EventAgentFinish, Agent: frame.Name, Text: last})
 			input = &RunInput{
 				Meta:           frame.Meta,
-				CallToolResult: mcp.NewToolResultText(agent.LastMessage()),
+				CallToolResult: mcp.NewToolResultText(last),
 			}
 			d.Stack = d.Stack[:len(d.Stack)-1]
 			continue
 		}
 
+		out, err := d.runHooks(ctx, &HookInput{
+			Event:     HookTurnEnd,
+			Agent:     frame.Name,
+			Text:      agent.LastMessage(),
+			Continued: continued,
+		})
+		if err != nil {
+			d.emit(Event{Type: EventError, Agent: frame.Name, Text: err.Error()})
+			return err
+		}
+		if out.Blocked() {
+			prompt := out.Message
+			if prompt == "" {
+				prompt = "Continue."
+			}
+			continued = true
+			input = &RunInput{Meta: output.Meta, Prompt: prompt}
+			continue
+		}
 		break
 	}
 	return nil
 }
 
+// submit runs the user_prompt_submit hooks, which may replace
+// the input's prompt or add to it. For inputs which only have
+// messages, the prompt is sent after the messages.
+func (d *Driver) submit(ctx context.Context, input *RunInput) error {
+	out, err := d.runHooks(ctx, &HookInput{
+		Event:    HookUserPromptSubmit,
+		Agent:    d.Stack[len(d.Stack)-1].Name,
+		Prompt:   input.Prompt,
+		Messages: input.Messages,
+	})
+	if err != nil {
+		return err
+	}
+	if out.Blocked() {
+		return &HookBlockedError{Message: out.Message}
+	}
+	if out.Prompt != "" {
+		input.Prompt = out.Prompt
+	}
+	if out.Message != "" {
+		if input.Prompt == "" {
+			input.Prompt = out.Message
+		} else {
+			input.Prompt += "\n\n" + out.Message
+		}
+	}
+	return nil
+}
+
+func (d *Driver) emit(e Event) {
+	if d.Sink == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	d.Sink.Event(e)
+}
+
 func (d *Driver) tools() []mcp.Tool {
 	var tools []mcp.Tool
 	for _, t := range d.Tools {
 		tools = append(tools, t.ToAlias())
 	}
+	if d.Servers != nil && d.Servers.hasResources() {
+		tools = append(tools, resourceTools()...)
+	}
 	return append(tools, mcp.NewTool("run_agent",
 		mcp.WithDescription(strings.Join([]string{
 			"Run a child agent to execute a sub-task.",
@@ -155,7 +305,54 @@ func (d *Driver) tools() []mcp.Tool {
 	))
 }
 
-func (d *Driver) call(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// call runs the tool call hooks around the tool call. Blocked calls
+// are reported to the model as tool errors.
+func (d *Driver) call(ctx context.Context, agent string, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pre, err := d.runHooks(ctx, &HookInput{
+		Event:     HookPreToolCall,
+		Agent:     agent,
+		Tool:      req.Params.Name,
+		Arguments: req.Params.Arguments,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pre.Blocked() {
+		return blockedError(pre), nil
+	}
+	if pre.Arguments != nil {
+		req.Params.Arguments = pre.Arguments
+	}
+	res, err := d.dispatch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	post, err := d.runHooks(ctx, &HookInput{
+		Event:     HookPostToolCall,
+		Agent:     agent,
+		Tool:      req.Params.Name,
+		Arguments: req.Params.Arguments,
+		Result:    res,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if post.Blocked() {
+		return blockedError(post), nil
+	}
+	if post.Message != "" {
+		res.Content = append(res.Content, mcp.NewTextContent(post.Message))
+	}
+	return res, nil
+}
+
+// dispatch sends the tool call to the tool's server.
+func (d *Driver) dispatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if d.Servers != nil {
+		if res, ok := d.callResourceTool(ctx, req); ok {
+			return res, nil
+		}
+	}
 	var found bool
 	var tool Tool
 	for _, t := range d.Tools {
@@ -168,6 +365,10 @@ func (d *Driver) call(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTo
 	if !found {
 		return mcpx.NewToolResultErrorf("tool not found: %q", req.Params.Name), nil
 	}
+	// report malformed calls to the model instead of the server
+	if err := mcpx.ValidateArguments(tool.InputSchema(), req.Params.Arguments); err != nil {
+		return mcpx.NewToolResultErrorf("invalid arguments for tool %s:\n%v", req.Params.Name, err), nil
+	}
 	// replace the alias name with the actual name before making request
 	req.Params.Name = tool.Tool.Name
 	return tool.Client.CallTool(ctx, req)
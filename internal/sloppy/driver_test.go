@@ -0,0 +1,127 @@
+package sloppy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestServers returns a server manager running s under the name "test".
+func newTestServers(t *testing.T, s *server.MCPServer) *ServerManager {
+	t.Helper()
+	m := NewServerManager()
+	t.Cleanup(func() { m.Close() })
+	if err := m.Add(context.Background(), "test", inProcessConnect(s)); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// inProcessConnect returns a ConnectFunc which connects to s.
+func inProcessConnect(s *server.MCPServer) ConnectFunc {
+	return func(ctx context.Context) (*client.Client, error) {
+		return mcpx.NewInProcessClient(s)
+	}
+}
+
+func TestDriverDispatch(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0")
+	echo := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		data, _ := json.Marshal(req.Params.Arguments)
+		return mcp.NewToolResultText(string(data)), nil
+	}
+	s.AddTool(mcp.NewTool("open",
+		mcp.WithString("path", mcp.Required()),
+		mcp.WithNumber("line"),
+	), echo)
+	s.AddTool(mcp.NewToolWithRawSchema("strict", "", json.RawMessage(`{
+		"type": "object",
+		"properties": {"path": {"type": "string"}},
+		"additionalProperties": false
+	}`)), echo)
+	d := &Driver{Servers: newTestServers(t, s)}
+	d.Tools = d.Servers.Tools()
+	tests := []struct {
+		name    string
+		tool    string
+		args    string
+		text    string
+		isError bool
+	}{
+		{
+			name: "valid",
+			tool: "test-open",
+			args: `{"path": "main.go", "line": 12}`,
+			text: `{"line":12,"path":"main.go"}`,
+		},
+		{
+			name:    "missing required",
+			tool:    "test-open",
+			args:    `{"line": 12}`,
+			text:    "invalid arguments for tool test-open:\npath: missing required property",
+			isError: true,
+		},
+		{
+			name:    "wrong type",
+			tool:    "test-open",
+			args:    `{"path": "main.go", "line": "12"}`,
+			text:    "invalid arguments for tool test-open:\nline: expected number, got string",
+			isError: true,
+		},
+		{
+			name: "unlisted argument",
+			tool: "test-open",
+			args: `{"path": "main.go", "column": 3}`,
+			text: `{"column":3,"path":"main.go"}`,
+		},
+		{
+			name:    "additional properties false",
+			tool:    "test-strict",
+			args:    `{"path": "main.go", "column": 3}`,
+			text:    "invalid arguments for tool test-strict:\ncolumn: unknown property",
+			isError: true,
+		},
+		{
+			name:    "unknown tool",
+			tool:    "test-missing",
+			args:    `{}`,
+			text:    `tool not found: "test-missing"`,
+			isError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req mcp.CallToolRequest
+			req.Params.Name = tt.tool
+			if err := json.Unmarshal([]byte(tt.args), &req.Params.Arguments); err != nil {
+				t.Fatal(err)
+			}
+			res, err := d.dispatch(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.IsError != tt.isError {
+				t.Fatalf("got IsError %v, want %v", res.IsError, tt.isError)
+			}
+			if got := resultText(res); got != tt.text {
+				t.Fatalf("got %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func resultText(res *mcp.CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		if text, ok := c.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,161 @@
+package sloppy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/icholy/sloppy/internal/markdown"
+	"github.com/icholy/sloppy/internal/termcolor"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type EventType string
+
+const (
+	// EventTurnStart is emitted before an agent is run.
+	EventTurnStart EventType = "turn_start"
+	// EventText is text written by an agent.
+	EventText EventType = "text"
+	// EventToolCall is a tool call requested by an agent.
+	EventToolCall EventType = "tool_call"
+	// EventToolResult is the result of a tool call.
+	EventToolResult EventType = "tool_result"
+	// EventAgentStart is a child agent being started. Text is the prompt.
+	EventAgentStart EventType = "agent_start"
+	// EventAgentFinish is a child agent finishing. Text is its final message.
+	EventAgentFinish EventType = "agent_finish"
+	// EventUsage is the token usage of a model request.
+	EventUsage EventType = "usage"
+	// EventError is an error which stopped the loop.
+	EventError EventType = "error"
+)
+
+// Usage is the number of tokens used by a model request.
+type Usage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// Add adds the other usage to u.
+func (u *Usage) Add(other Usage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+}
+
+// Event is something which happened while running the driver loop.
+type Event struct {
+	Type      EventType           `json:"type"`
+	Time      time.Time           `json:"time"`
+	Agent     string              `json:"agent,omitempty"`
+	Text      string              `json:"text,omitempty"`
+	Tool      string              `json:"tool,omitempty"`
+	Arguments any                 `json:"arguments,omitempty"`
+	Result    *mcp.CallToolResult `json:"result,omitempty"`
+	Usage     *Usage              `json:"usage,omitempty"`
+}
+
+// EventSink receives the events emitted by the driver.
+type EventSink interface {
+	Event(e Event)
+}
+
+// EventFunc is an adapter to allow the use of ordinary functions as event sinks.
+type EventFunc func(e Event)
+
+func (f EventFunc) Event(e Event) { f(e) }
+
+// MultiSink sends every event to all of the sinks.
+func MultiSink(sinks ...EventSink) EventSink {
+	return EventFunc(func(e Event) {
+		for _, s := range sinks {
+			s.Event(e)
+		}
+	})
+}
+
+// TerminalSink prints agent text and tool calls for a human to read.
+// Agent text is rendered as markdown when colors are enabled.
+type TerminalSink struct {
+	mu    sync.Mutex
+	w     io.Writer
+	color bool
+}
+
+func NewTerminalSink(w io.Writer) *TerminalSink {
+	return &TerminalSink{w: w, color: termcolor.Enabled(w)}
+}
+
+func (s *TerminalSink) Event(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Type {
+	case EventText:
+		name := e.Agent
+		if name == RootAgent {
+			name = "Sloppy"
+		}
+		if s.color {
+			fmt.Fprintf(s.w, "%s: %s\n", termcolor.Text(name, termcolor.Yellow), markdown.Render(e.Text))
+		} else {
+			fmt.Fprintf(s.w, "%s: %s\n", name, e.Text)
+		}
+	case EventToolCall:
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(e.Arguments)
+		data := strings.TrimSpace(buf.String())
+		fmt.Fprintf(s.w, "tool: %s %s\n", e.Tool, data)
+	}
+}
+
+// JSONLSink writes every event as a line of JSON.
+type JSONLSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &JSONLSink{enc: enc}
+}
+
+func (s *JSONLSink) Event(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(e)
+}
+
+// EventRecorder records events so they can be inspected later.
+// It's intended for testing.
+type EventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *EventRecorder) Event(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+// Events returns the recorded events.
+func (r *EventRecorder) Events() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// Reset discards the recorded events.
+func (r *EventRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = nil
+}
@@ -0,0 +1,199 @@
+package sloppy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// scriptedAgent returns the outputs in order.
+// A nil output returns an error.
+type scriptedAgent struct {
+	outputs []*RunOutput
+	last    string
+}
+
+func (a *scriptedAgent) Run(ctx context.Context, input *RunInput) (*RunOutput, error) {
+	if len(a.outputs) == 0 {
+		return nil, errors.New("no more outputs")
+	}
+	out := a.outputs[0]
+	a.outputs = a.outputs[1:]
+	if out == nil {
+		return nil, errors.New("model error")
+	}
+	if len(out.Text) > 0 {
+		a.last = out.Text[len(out.Text)-1]
+	}
+	return out, nil
+}
+
+func (a *scriptedAgent) LastMessage() string {
+	return a.last
+}
+
+// toolCall returns an output which calls the tool.
+func toolCall(name string, args map[string]any) *RunOutput {
+	req := &mcp.CallToolRequest{}
+	req.Params.Name = name
+	req.Params.Arguments = args
+	return &RunOutput{CallToolRequest: req, Meta: map[string]any{"id": name}}
+}
+
+// eventString summarizes the event for comparisons.
+func eventString(e Event) string {
+	s := fmt.Sprintf("%s %s", e.Type, e.Agent)
+	if e.Tool != "" {
+		s += " " + e.Tool
+	}
+	if e.Text != "" {
+		s += ": " + e.Text
+	}
+	if e.Usage != nil {
+		s += fmt.Sprintf(": %d/%d", e.Usage.InputTokens, e.Usage.OutputTokens)
+	}
+	return s
+}
+
+func TestDriverEvents(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0")
+	s.AddTool(mcp.NewTool("echo", mcp.WithString("text")), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		text, _ := req.Params.Arguments["text"].(string)
+		return mcp.NewToolResultText(text), nil
+	})
+	servers := newTestServers(t, s)
+	tests := []struct {
+		name     string
+		agents   map[string][]*RunOutput
+		maxTurns int
+		err      bool
+		events   []string
+	}{
+		{
+			name: "text",
+			agents: map[string][]*RunOutput{
+				"": {{Text: []string{"hello"}, Usage: &Usage{InputTokens: 3, OutputTokens: 1}}},
+			},
+			events: []string{
+				"turn_start sloppy",
+				"usage sloppy: 3/1",
+				"text sloppy: hello",
+			},
+		},
+		{
+			name: "tool call",
+			agents: map[string][]*RunOutput{
+				"": {
+					toolCall("test-echo", map[string]any{"text": "hi"}),
+					{Text: []string{"done"}},
+				},
+			},
+			events: []string{
+				"turn_start sloppy",
+				"tool_call sloppy test-echo",
+				"tool_result sloppy test-echo",
+				"turn_start sloppy",
+				"text sloppy: done",
+			},
+		},
+		{
+			name: "child agent",
+			agents: map[string][]*RunOutput{
+				"": {
+					toolCall("run_agent", map[string]any{"name": "child", "prompt": "do it"}),
+					{Text: []string{"all done"}},
+				},
+				"child": {{Text: []string{"did it"}}},
+			},
+			events: []string{
+				"turn_start sloppy",
+				"tool_call sloppy run_agent",
+				"agent_start child: do it",
+				"turn_start child",
+				"text child: did it",
+				"agent_finish child: did it",
+				"turn_start sloppy",
+				"text sloppy: all done",
+			},
+		},
+		{
+			name: "model error",
+			agents: map[string][]*RunOutput{
+				"": {nil},
+			},
+			err: true,
+			events: []string{
+				"turn_start sloppy",
+				"error sloppy: model error",
+			},
+		},
+		{
+			name: "max turns",
+			agents: map[string][]*RunOutput{
+				"": {toolCall("test-echo", nil), toolCall("test-echo", nil)},
+			},
+			maxTurns: 1,
+			err:      true,
+			events: []string{
+				"turn_start sloppy",
+				"tool_call sloppy test-echo",
+				"tool_result sloppy test-echo",
+				"error sloppy: " + ErrMaxTurns.Error(),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recorder EventRecorder
+			d := &Driver{
+				Servers:  servers,
+				Sink:     &recorder,
+				MaxTurns: tt.maxTurns,
+				NewAgent: func(name string) Agent {
+					return &scriptedAgent{outputs: tt.agents[name]}
+				},
+			}
+			if err := d.Loop(context.Background(), "go"); (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var events []string
+			for _, e := range recorder.Events() {
+				if e.Time.IsZero() {
+					t.Fatalf("event has no time: %+v", e)
+				}
+				events = append(events, eventString(e))
+			}
+			if !slices.Equal(events, tt.events) {
+				t.Fatalf("got events:\n%q\nwant:\n%q", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestTerminalSink(t *testing.T) {
+	tests := []struct {
+		event Event
+		want  string
+	}{
+		{event: Event{Type: EventText, Agent: RootAgent, Text: "hello"}, want: "Sloppy: hello\n"},
+		{event: Event{Type: EventText, Agent: "child", Text: "hi"}, want: "child: hi\n"},
+		{
+			event: Event{Type: EventToolCall, Agent: RootAgent, Tool: "test-echo", Arguments: map[string]any{"text": "<b>"}},
+			want:  "tool: test-echo {\n  \"text\": \"<b>\"\n}\n",
+		},
+		{event: Event{Type: EventUsage, Agent: RootAgent, Usage: &Usage{}}, want: ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		NewTerminalSink(&buf).Event(tt.event)
+		if buf.String() != tt.want {
+			t.Fatalf("%s: got %q, want %q", tt.event.Type, buf.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package sloppy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// HookEvent is a point in the driver loop where hooks are run.
+type HookEvent string
+
+const (
+	// HookPreToolCall runs before a tool is called. It may block the
+	// call or replace its arguments.
+	HookPreToolCall HookEvent = "pre_tool_call"
+	// HookPostToolCall runs after a tool is called. It may replace the
+	// result with an error or add a message to it.
+	HookPostToolCall HookEvent = "post_tool_call"
+	// HookUserPromptSubmit runs before a prompt is sent to the agent.
+	// It may block the prompt, replace it, or add a message to it.
+	HookUserPromptSubmit HookEvent = "user_prompt_submit"
+	// HookTurnEnd runs when the agent has finished responding. Blocking
+	// sends the message back to the agent so that it keeps going.
+	HookTurnEnd HookEvent = "turn_end"
+)
+
+// DefaultHookTimeout is used when a hook doesn't specify a timeout.
+const DefaultHookTimeout = 60 * time.Second
+
+// Hook is a shell command which runs on an event. The command receives
+// a HookInput as JSON on stdin, and may write a HookOutput as JSON to
+// stdout. Exiting with status 2 blocks, using stderr as the message.
+// Any other non-zero exit status is an error.
+type Hook struct {
+	Event HookEvent
+	// Matcher is a glob pattern for the tool name. It's only used by
+	// tool call hooks, which match every tool when it's empty.
+	Matcher string
+	Command string
+	Timeout time.Duration
+}
+
+// HookInput describes the event.
+type HookInput struct {
+	Event     HookEvent           `json:"event"`
+	Agent     string              `json:"agent"`
+	Tool      string              `json:"tool,omitempty"`
+	Arguments map[string]any      `json:"arguments,omitempty"`
+	Result    *mcp.CallToolResult `json:"result,omitempty"`
+	Prompt    string              `json:"prompt,omitempty"`
+	// Messages are the messages from an MCP prompt, which are
+	// submitted along with the prompt.
+	Messages []mcp.PromptMessage `json:"messages,omitempty"`
+	// Text is the agent's final message.
+	Text string `json:"text,omitempty"`
+	// Continued is set when the turn was started by a turn_end hook
+	// blocking. Hooks can use it to avoid looping forever.
+	Continued bool `json:"continued,omitempty"`
+}
+
+// HookOutput is the hook's decision.
+type HookOutput struct {
+	// Decision is either "allow" or "block". It defaults to "allow".
+	Decision string `json:"decision,omitempty"`
+	// Message is sent to the model. When blocking, it explains why.
+	// When allowing, it's added to the tool result or prompt.
+	Message string `json:"message,omitempty"`
+	// Arguments replace the tool call's arguments.
+	Arguments map[string]any `json:"arguments,omitempty"`
+	// Prompt replaces the user's prompt.
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Blocked reports whether the hook blocked the event.
+func (o *HookOutput) Blocked() bool {
+	return o.Decision == "block"
+}
+
+// Matches reports whether the hook should run for the input.
+func (h *Hook) Matches(in *HookInput) (bool, error) {
+	if h.Event != in.Event {
+		return false, nil
+	}
+	if h.Matcher == "" || in.Tool == "" {
+		return true, nil
+	}
+	ok, err := path.Match(h.Matcher, in.Tool)
+	if err != nil {
+		return false, fmt.Errorf("invalid hook matcher: %q: %w", h.Matcher, err)
+	}
+	return ok, nil
+}
+
+// Run runs the hook's command.
+func (h *Hook) Run(ctx context.Context, in *HookInput) (*HookOutput, error) {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	data, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command)
+	cmd.Env = append(os.Environ(), "SLOPPY_HOOK_EVENT="+string(in.Event))
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// background processes which keep the output open aren't waited for
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() == 2 {
+			return &HookOutput{
+				Decision: "block",
+				Message:  strings.TrimSpace(stderr.String()),
+			}, nil
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("hook failed: %s: %w", in.Event, err)
+	}
+	var out HookOutput
+	// output which isn't a json object is ignored
+	if text := bytes.TrimSpace(stdout.Bytes()); bytes.HasPrefix(text, []byte("{")) {
+		if err := json.Unmarshal(text, &out); err != nil {
+			return nil, fmt.Errorf("invalid hook output: %s: %w", in.Event, err)
+		}
+	}
+	switch out.Decision {
+	case "", "allow", "block":
+	default:
+		return nil, fmt.Errorf("invalid hook decision: %s: %q", in.Event, out.Decision)
+	}
+	return &out, nil
+}
+
+// runHooks runs the matching hooks in order. Replaced arguments and
+// prompts are passed on to the following hooks, and messages are joined.
+// It stops at the first hook which blocks.
+func (d *Driver) runHooks(ctx context.Context, in *HookInput) (*HookOutput, error) {
+	var result HookOutput
+	var messages []string
+	for _, h := range d.Hooks {
+		ok, err := h.Matches(in)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		out, err := h.Run(ctx, in)
+		if err != nil {
+			return nil, err
+		}
+		if out.Message != "" {
+			messages = append(messages, out.Message)
+		}
+		if out.Arguments != nil {
+			in.Arguments = out.Arguments
+			result.Arguments = out.Arguments
+		}
+		if out.Prompt != "" {
+			in.Prompt = out.Prompt
+			result.Prompt = out.Prompt
+		}
+		if out.Blocked() {
+			result.Decision = out.Decision
+			result.Message = out.Message
+			return &result, nil
+		}
+	}
+	result.Message = strings.Join(messages, "\n")
+	return &result, nil
+}
+
+// blockedError returns a tool result which tells the model that
+// a hook blocked the tool call.
+func blockedError(out *HookOutput) *mcp.CallToolResult {
+	if out.Message == "" {
+		return mcp.NewToolResultError("blocked by hook")
+	}
+	return mcpx.NewToolResultErrorf("blocked by hook: %s", out.Message)
+}
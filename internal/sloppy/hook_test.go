@@ -0,0 +1,328 @@
+package sloppy
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHookMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    Hook
+		in      HookInput
+		matches bool
+		err     bool
+	}{
+		{
+			name:    "other event",
+			hook:    Hook{Event: HookPreToolCall},
+			in:      HookInput{Event: HookPostToolCall, Tool: "builtin-read_file"},
+			matches: false,
+		},
+		{
+			name:    "no matcher",
+			hook:    Hook{Event: HookPreToolCall},
+			in:      HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			matches: true,
+		},
+		{
+			name:    "glob",
+			hook:    Hook{Event: HookPreToolCall, Matcher: "builtin-*"},
+			in:      HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			matches: true,
+		},
+		{
+			name:    "glob mismatch",
+			hook:    Hook{Event: HookPreToolCall, Matcher: "github-*"},
+			in:      HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			matches: false,
+		},
+		{
+			name:    "matcher ignored without a tool",
+			hook:    Hook{Event: HookUserPromptSubmit, Matcher: "github-*"},
+			in:      HookInput{Event: HookUserPromptSubmit, Prompt: "hi"},
+			matches: true,
+		},
+		{
+			name: "invalid matcher",
+			hook: Hook{Event: HookPreToolCall, Matcher: "["},
+			in:   HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.hook.Matches(&tt.in)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.matches {
+				t.Fatalf("got %v, want %v", ok, tt.matches)
+			}
+		})
+	}
+}
+
+func TestHookRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		out     HookOutput
+		err     string
+	}{
+		{
+			name:    "no output",
+			command: "true",
+		},
+		{
+			name:    "text output is ignored",
+			command: "echo hello",
+		},
+		{
+			name:    "json output",
+			command: `echo '{"decision": "allow", "message": "note", "prompt": "new"}'`,
+			out:     HookOutput{Decision: "allow", Message: "note", Prompt: "new"},
+		},
+		{
+			name:    "block",
+			command: `echo '{"decision": "block", "message": "no"}'`,
+			out:     HookOutput{Decision: "block", Message: "no"},
+		},
+		{
+			name:    "exit status 2",
+			command: "echo denied >&2; exit 2",
+			out:     HookOutput{Decision: "block", Message: "denied"},
+		},
+		{
+			name:    "failure",
+			command: "echo oops >&2; exit 1",
+			err:     "hook failed: pre_tool_call: exit status 1: oops",
+		},
+		{
+			name:    "invalid json",
+			command: "echo '{'",
+			err:     "invalid hook output: pre_tool_call",
+		},
+		{
+			name:    "invalid decision",
+			command: `echo '{"decision": "maybe"}'`,
+			err:     `invalid hook decision: pre_tool_call: "maybe"`,
+		},
+		{
+			name:    "event variable",
+			command: `echo "{\"message\": \"$SLOPPY_HOOK_EVENT\"}"`,
+			out:     HookOutput{Message: "pre_tool_call"},
+		},
+		{
+			name:    "input on stdin",
+			command: `grep -q '"tool":"builtin-read_file"' && echo '{"message": "found"}'`,
+			out:     HookOutput{Message: "found"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Hook{Event: HookPreToolCall, Command: tt.command}
+			out, err := h.Run(context.Background(), &HookInput{
+				Event: HookPreToolCall,
+				Agent: "sloppy",
+				Tool:  "builtin-read_file",
+			})
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*out, tt.out) {
+				t.Fatalf("got %+v, want %+v", *out, tt.out)
+			}
+		})
+	}
+}
+
+func TestHookRunTimeout(t *testing.T) {
+	h := Hook{Event: HookTurnEnd, Command: "sleep 10", Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	if _, err := h.Run(context.Background(), &HookInput{Event: HookTurnEnd}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("took %v", elapsed)
+	}
+}
+
+func TestHookRunBackgroundProcess(t *testing.T) {
+	// the background process inherits stdout, so the hook
+	// mustn't wait for it to close
+	h := Hook{Event: HookTurnEnd, Command: `sleep 10 & echo '{"message": "done"}'`}
+	start := time.Now()
+	out, err := h.Run(context.Background(), &HookInput{Event: HookTurnEnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Message != "done" {
+		t.Fatalf("got message %q", out.Message)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("took %v", elapsed)
+	}
+}
+
+func TestDriverRunHooks(t *testing.T) {
+	tests := []struct {
+		name  string
+		hooks []Hook
+		in    HookInput
+		out   HookOutput
+	}{
+		{
+			name: "no hooks",
+			in:   HookInput{Event: HookUserPromptSubmit, Prompt: "hi"},
+		},
+		{
+			name: "messages are joined",
+			hooks: []Hook{
+				{Event: HookUserPromptSubmit, Command: `echo '{"message": "a"}'`},
+				{Event: HookUserPromptSubmit, Command: `echo '{"message": "b"}'`},
+			},
+			in:  HookInput{Event: HookUserPromptSubmit, Prompt: "hi"},
+			out: HookOutput{Message: "a\nb"},
+		},
+		{
+			name: "arguments are chained",
+			hooks: []Hook{
+				{Event: HookPreToolCall, Command: `echo '{"arguments": {"path": "a"}}'`},
+				{Event: HookPreToolCall, Command: `grep -q '"path":"a"' && echo '{"arguments": {"path": "b"}}'`},
+			},
+			in:  HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			out: HookOutput{Arguments: map[string]any{"path": "b"}},
+		},
+		{
+			name: "first block wins",
+			hooks: []Hook{
+				{Event: HookPreToolCall, Command: `echo '{"message": "a"}'`},
+				{Event: HookPreToolCall, Command: "echo first >&2; exit 2"},
+				{Event: HookPreToolCall, Command: "echo second >&2; exit 2"},
+			},
+			in:  HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+			out: HookOutput{Decision: "block", Message: "first"},
+		},
+		{
+			name: "unmatched hooks are skipped",
+			hooks: []Hook{
+				{Event: HookPreToolCall, Matcher: "github-*", Command: "exit 2"},
+				{Event: HookPostToolCall, Command: "exit 2"},
+			},
+			in: HookInput{Event: HookPreToolCall, Tool: "builtin-read_file"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &Driver{Hooks: tt.hooks}
+			out, err := d.runHooks(context.Background(), &tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*out, tt.out) {
+				t.Fatalf("got %+v, want %+v", *out, tt.out)
+			}
+		})
+	}
+}
+
+// testAgent records its inputs and responds with a single message.
+type testAgent struct {
+	inputs []*RunInput
+}
+
+func (a *testAgent) Run(ctx context.Context, input *RunInput) (*RunOutput, error) {
+	a.inputs = append(a.inputs, input)
+	return &RunOutput{Text: []string{"done"}}, nil
+}
+
+func (a *testAgent) LastMessage() string {
+	return "done"
+}
+
+func TestDriverUserPromptSubmit(t *testing.T) {
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("review the pr")),
+	}
+	tests := []struct {
+		name    string
+		command string
+		input   RunInput
+		prompt  string
+		blocked bool
+	}{
+		{
+			name:    "prompt",
+			command: `echo '{"message": "be brief"}'`,
+			input:   RunInput{Prompt: "hi"},
+			prompt:  "hi\n\nbe brief",
+		},
+		{
+			name:    "replace prompt",
+			command: `echo '{"prompt": "hello"}'`,
+			input:   RunInput{Prompt: "hi"},
+			prompt:  "hello",
+		},
+		{
+			name:    "block prompt",
+			command: "exit 2",
+			input:   RunInput{Prompt: "hi"},
+			blocked: true,
+		},
+		{
+			name:    "messages",
+			command: `grep -q 'review the pr' && echo '{"message": "be brief"}'`,
+			input:   RunInput{Messages: messages},
+			prompt:  "be brief",
+		},
+		{
+			name:    "block messages",
+			command: "exit 2",
+			input:   RunInput{Messages: messages},
+			blocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := &testAgent{}
+			d := &Driver{
+				NewAgent: func(name string) Agent { return agent },
+				Hooks:    []Hook{{Event: HookUserPromptSubmit, Command: tt.command}},
+			}
+			input := tt.input
+			err := d.LoopInput(context.Background(), &input)
+			var blocked *HookBlockedError
+			if errors.As(err, &blocked) != tt.blocked {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.blocked {
+				if len(agent.inputs) != 0 {
+					t.Fatal("the agent was run")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(agent.inputs) != 1 {
+				t.Fatalf("got %d agent runs", len(agent.inputs))
+			}
+			if got := agent.inputs[0].Prompt; got != tt.prompt {
+				t.Fatalf("got prompt %q, want %q", got, tt.prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package sloppy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerPrompts contains the prompts advertised by a server.
+type ServerPrompts struct {
+	Server  string
+	Prompts []mcp.Prompt
+}
+
+// Prompts lists the prompts from every running server which supports them.
+// The prompts are cached until the server reports that they've changed.
+func (m *ServerManager) Prompts(ctx context.Context) ([]ServerPrompts, error) {
+	type listing struct {
+		s       *managedServer
+		gen     int
+		c       *client.Client
+		prompts ServerPrompts
+		cached  bool
+	}
+	var listings []listing
+	m.mu.Lock()
+	for _, s := range m.servers {
+		if s.client == nil || s.client.GetServerCapabilities().Prompts == nil {
+			continue
+		}
+		listings = append(listings, listing{
+			s:       s,
+			gen:     s.promptsGen,
+			c:       s.client,
+			prompts: ServerPrompts{Server: s.name, Prompts: s.prompts},
+			cached:  s.promptsCached,
+		})
+	}
+	m.mu.Unlock()
+	var all []ServerPrompts
+	for _, l := range listings {
+		if !l.cached {
+			res, err := l.c.ListPrompts(ctx, mcp.ListPromptsRequest{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list prompts: %s: %w", l.s.name, err)
+			}
+			l.prompts.Prompts = res.Prompts
+			m.mu.Lock()
+			if l.s.client == l.c && l.s.promptsGen == l.gen {
+				l.s.prompts = res.Prompts
+				l.s.promptsCached = true
+			}
+			m.mu.Unlock()
+		}
+		all = append(all, l.prompts)
+	}
+	return all, nil
+}
+
+// GetPrompt gets a prompt from the named server.
+func (m *ServerManager) GetPrompt(ctx context.Context, server, name string, args map[string]string) (*mcp.GetPromptResult, error) {
+	c, ok := m.Client(server)
+	if !ok {
+		return nil, fmt.Errorf("server not found: %q", server)
+	}
+	var req mcp.GetPromptRequest
+	req.Params.Name = name
+	req.Params.Arguments = args
+	res, err := c.GetPrompt(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prompt: %s: %w", name, err)
+	}
+	return res, nil
+}
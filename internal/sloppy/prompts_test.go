@@ -0,0 +1,68 @@
+package sloppy
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestServerManagerPromptsCache(t *testing.T) {
+	var lists atomic.Int32
+	hooks := &server.Hooks{}
+	hooks.AddBeforeListPrompts(func(ctx context.Context, id any, req *mcp.ListPromptsRequest) {
+		lists.Add(1)
+	})
+	s := server.NewMCPServer("test", "1.0.0", server.WithPromptCapabilities(true), server.WithHooks(hooks))
+	handler := func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("", nil), nil
+	}
+	s.AddPrompt(mcp.NewPrompt("review"), handler)
+	m := newTestServers(t, s)
+	names := func() []string {
+		t.Helper()
+		prompts, err := m.Prompts(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, sp := range prompts {
+			for _, p := range sp.Prompts {
+				names = append(names, sp.Server+":"+p.Name)
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+	for range 3 {
+		if got := names(); !slices.Equal(got, []string{"test:review"}) {
+			t.Fatalf("got prompts %q", got)
+		}
+	}
+	if n := lists.Load(); n != 1 {
+		t.Fatalf("prompts were listed %d times, want 1", n)
+	}
+	// adding a prompt sends a list_changed notification
+	s.AddPrompt(mcp.NewPrompt("explain"), handler)
+	want := []string{"test:explain", "test:review"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(names(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got prompts %q, want %q", names(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// restarting the server clears the cache
+	before := lists.Load()
+	if err := m.Restart(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	names()
+	if n := lists.Load(); n != before+1 {
+		t.Fatalf("prompts were listed %d times after restarting, want %d", n, before+1)
+	}
+}
@@ -0,0 +1,251 @@
+package sloppy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerResources contains the resources advertised by a server.
+type ServerResources struct {
+	Server    string
+	Resources []mcp.Resource
+	Templates []mcp.ResourceTemplate
+	// Err is set if the server's resources couldn't be listed.
+	Err error
+}
+
+// Client returns the named server's active client.
+func (m *ServerManager) Client(name string) (*client.Client, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.find(name)
+	if s == nil || s.client == nil {
+		return nil, false
+	}
+	return s.client, true
+}
+
+// clients returns the active clients keyed by server name in server order.
+func (m *ServerManager) clients() ([]string, []*client.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	var clients []*client.Client
+	for _, s := range m.servers {
+		if s.client != nil {
+			names = append(names, s.name)
+			clients = append(clients, s.client)
+		}
+	}
+	return names, clients
+}
+
+// hasResources reports whether any running server supports resources.
+func (m *ServerManager) hasResources() bool {
+	_, clients := m.clients()
+	for _, c := range clients {
+		if c.GetServerCapabilities().Resources != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Resources lists the resources and resource templates from every
+// running server which supports them. Servers which fail to list
+// their resources are included with Err set.
+func (m *ServerManager) Resources(ctx context.Context) []ServerResources {
+	var all []ServerResources
+	names, clients := m.clients()
+	for i, c := range clients {
+		if c.GetServerCapabilities().Resources == nil {
+			continue
+		}
+		res, err := ListClientResources(ctx, names[i], c)
+		if err != nil {
+			res = &ServerResources{Server: names[i], Err: err}
+		}
+		all = append(all, *res)
+	}
+	return all
+}
+
+// ReadResource reads a resource from the named server.
+func (m *ServerManager) ReadResource(ctx context.Context, server, uri string) ([]mcp.ResourceContents, error) {
+	c, ok := m.Client(server)
+	if !ok {
+		return nil, fmt.Errorf("server not found: %q", server)
+	}
+	var req mcp.ReadResourceRequest
+	req.Params.URI = uri
+	res, err := c.ReadResource(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resource: %s: %w", uri, err)
+	}
+	return res.Contents, nil
+}
+
+// ListClientResources lists the resources and templates from a client.
+// Servers which don't implement either list have none of that kind.
+func ListClientResources(ctx context.Context, name string, c *client.Client) (*ServerResources, error) {
+	var resources mcp.ListResourcesResult
+	err := mcpx.Request(ctx, c, string(mcp.MethodResourcesList), nil, &resources)
+	if err != nil && !mcpx.IsMethodNotFound(err) {
+		return nil, fmt.Errorf("failed to list resources: %s: %w", name, err)
+	}
+	var templates mcp.ListResourceTemplatesResult
+	err = mcpx.Request(ctx, c, string(mcp.MethodResourcesTemplatesList), nil, &templates)
+	if err != nil && !mcpx.IsMethodNotFound(err) {
+		return nil, fmt.Errorf("failed to list resource templates: %s: %w", name, err)
+	}
+	return &ServerResources{
+		Server:    name,
+		Resources: resources.Resources,
+		Templates: templates.ResourceTemplates,
+	}, nil
+}
+
+// FormatResourceContents formats resource contents so they can be
+// included in a prompt or tool result.
+func FormatResourceContents(server string, contents []mcp.ResourceContents) string {
+	var b strings.Builder
+	open := func(uri, mimeType string) {
+		b.WriteString("<resource")
+		if server != "" {
+			fmt.Fprintf(&b, " server=%q", server)
+		}
+		fmt.Fprintf(&b, " uri=%q mime_type=%q>\n", uri, mimeType)
+	}
+	for _, c := range contents {
+		switch c := c.(type) {
+		case mcp.TextResourceContents:
+			open(c.URI, c.MIMEType)
+			b.WriteString(c.Text)
+			if !strings.HasSuffix(c.Text, "\n") {
+				b.WriteString("\n")
+			}
+			b.WriteString("</resource>\n")
+		case mcp.BlobResourceContents:
+			open(c.URI, c.MIMEType)
+			fmt.Fprintf(&b, "binary content (%d bytes base64 encoded) omitted\n", len(c.Blob))
+			b.WriteString("</resource>\n")
+		}
+	}
+	return b.String()
+}
+
+// mentionRe matches @server:uri resource mentions.
+var mentionRe = regexp.MustCompile(`(^|\s)@([A-Za-z0-9_.-]+):(\S+)`)
+
+// ExpandMentions reads every @server:uri resource mention in the prompt and
+// appends the resource contents to it. Mentions which don't reference a
+// running server which supports resources are left alone.
+func (m *ServerManager) ExpandMentions(ctx context.Context, prompt string) (string, error) {
+	var attached []string
+	for _, match := range mentionRe.FindAllStringSubmatch(prompt, -1) {
+		server, uri := match[2], match[3]
+		c, ok := m.Client(server)
+		if !ok || c.GetServerCapabilities().Resources == nil {
+			continue
+		}
+		contents, err := m.ReadResource(ctx, server, uri)
+		if err != nil {
+			return "", err
+		}
+		attached = append(attached, FormatResourceContents(server, contents))
+	}
+	if len(attached) == 0 {
+		return prompt, nil
+	}
+	return prompt + "\n\n" + strings.Join(attached, "\n"), nil
+}
+
+func resourceTools() []mcp.Tool {
+	return []mcp.Tool{
+		mcp.NewTool("list_resources",
+			mcp.WithDescription("List the resources and resource templates provided by the MCP servers."),
+			mcp.WithString("server",
+				mcp.Description("Only list the resources from this server."),
+			),
+		),
+		mcp.NewTool("read_resource",
+			mcp.WithDescription("Read a resource from an MCP server."),
+			mcp.WithString("server",
+				mcp.Required(),
+				mcp.Description("The name of the server which provides the resource."),
+			),
+			mcp.WithString("uri",
+				mcp.Required(),
+				mcp.Description("The URI of the resource."),
+			),
+		),
+	}
+}
+
+// callResourceTool handles the list_resources and read_resource tools.
+// It returns false if the request is for a different tool.
+func (d *Driver) callResourceTool(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, bool) {
+	switch req.Params.Name {
+	case "list_resources":
+		var args struct {
+			Server string `param:"server"`
+		}
+		if err := mcpx.MapArguments(req.Params.Arguments, &args); err != nil {
+			return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), true
+		}
+		var b strings.Builder
+		for _, res := range d.Servers.Resources(ctx) {
+			if args.Server != "" && args.Server != res.Server {
+				continue
+			}
+			fmt.Fprintf(&b, "Server: %s\n", res.Server)
+			if res.Err != nil {
+				fmt.Fprintf(&b, "- error: %v\n", res.Err)
+				continue
+			}
+			for _, r := range res.Resources {
+				fmt.Fprintf(&b, "- %s (%s) %s %s\n", r.URI, r.Name, r.MIMEType, r.Description)
+			}
+			for _, t := range res.Templates {
+				if t.URITemplate == nil || t.URITemplate.Template == nil {
+					continue
+				}
+				fmt.Fprintf(&b, "- template: %s (%s) %s %s\n", t.URITemplate.Raw(), t.Name, t.MIMEType, t.Description)
+			}
+		}
+		if b.Len() == 0 {
+			return mcp.NewToolResultText("No resources available"), true
+		}
+		return mcp.NewToolResultText(b.String()), true
+	case "read_resource":
+		var args struct {
+			Server string `param:"server,required"`
+			URI    string `param:"uri,required"`
+		}
+		if err := mcpx.MapArguments(req.Params.Arguments, &args); err != nil {
+			return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), true
+		}
+		contents, err := d.Servers.ReadResource(ctx, args.Server, args.URI)
+		if err != nil {
+			return mcp.NewToolResultErrorFromErr("failed to read resource", err), true
+		}
+		// blobs are embedded so that images can be passed to the model
+		res := &mcp.CallToolResult{}
+		for _, c := range contents {
+			if blob, ok := c.(mcp.BlobResourceContents); ok {
+				res.Content = append(res.Content, mcp.NewEmbeddedResource(blob))
+			} else {
+				res.Content = append(res.Content, mcp.NewTextContent(FormatResourceContents(args.Server, []mcp.ResourceContents{c})))
+			}
+		}
+		return res, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,210 @@
+package sloppy
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// fakeTransport answers requests using a map from method to result.
+// Methods which aren't in the map aren't found, and error values
+// are returned as internal errors.
+type fakeTransport map[string]any
+
+func (t fakeTransport) Start(ctx context.Context) error { return nil }
+func (t fakeTransport) Close() error                    { return nil }
+
+func (t fakeTransport) SendNotification(ctx context.Context, n mcp.JSONRPCNotification) error {
+	return nil
+}
+
+func (t fakeTransport) SetNotificationHandler(handler func(mcp.JSONRPCNotification)) {}
+
+func (t fakeTransport) SendRequest(ctx context.Context, req transport.JSONRPCRequest) (*transport.JSONRPCResponse, error) {
+	res := &transport.JSONRPCResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: req.ID}
+	result, ok := t[req.Method]
+	if !ok {
+		res.Error = &struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data"`
+		}{Code: mcp.METHOD_NOT_FOUND, Message: "Method " + req.Method + " not found"}
+		return res, nil
+	}
+	if err, ok := result.(error); ok {
+		res.Error = &struct {
+			Code    int             `json:"code"`
+			Message string          `json:"message"`
+			Data    json.RawMessage `json:"data"`
+		}{Code: mcp.INTERNAL_ERROR, Message: err.Error()}
+		return res, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	res.Result = data
+	return res, nil
+}
+
+type testError string
+
+func (e testError) Error() string { return string(e) }
+
+// fakeResourceServer returns a transport for a server with resources.
+func fakeResourceServer(methods map[string]any) fakeTransport {
+	t := fakeTransport{
+		"initialize": map[string]any{
+			"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+			"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+			"capabilities":    map[string]any{"resources": map[string]any{}},
+		},
+	}
+	for method, result := range methods {
+		t[method] = result
+	}
+	return t
+}
+
+func TestServerManagerResources(t *testing.T) {
+	m := NewServerManager()
+	defer m.Close()
+	servers := map[string]fakeTransport{
+		"docs": fakeResourceServer(map[string]any{
+			"resources/list": map[string]any{
+				"resources": []any{map[string]any{"uri": "docs://readme", "name": "readme"}},
+			},
+			"resources/templates/list": map[string]any{
+				"resourceTemplates": []any{map[string]any{"uriTemplate": "docs://{page}", "name": "page"}},
+			},
+			"resources/read": map[string]any{
+				"contents": []any{map[string]any{"uri": "docs://readme", "mimeType": "text/plain", "text": "hello"}},
+			},
+		}),
+		// the templates method isn't implemented
+		"files": fakeResourceServer(map[string]any{
+			"resources/list": map[string]any{
+				"resources": []any{map[string]any{"uri": "file:///a", "name": "a"}},
+			},
+		}),
+		"broken": fakeResourceServer(map[string]any{
+			"resources/list": testError("database is down"),
+		}),
+		// no resources capability
+		"tools": fakeTransport{
+			"initialize": map[string]any{
+				"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+				"serverInfo":      map[string]any{"name": "fake", "version": "1.0.0"},
+				"capabilities":    map[string]any{},
+			},
+		},
+	}
+	for _, name := range []string{"broken", "docs", "files", "tools"} {
+		tr := servers[name]
+		err := m.Add(context.Background(), name, func(ctx context.Context) (*client.Client, error) {
+			return client.NewClient(tr), nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	all := m.Resources(context.Background())
+	tests := []struct {
+		server    string
+		resources []string
+		templates []string
+		err       string
+	}{
+		{server: "broken", err: "failed to list resources: broken: database is down"},
+		{server: "docs", resources: []string{"docs://readme"}, templates: []string{"docs://{page}"}},
+		{server: "files", resources: []string{"file:///a"}},
+	}
+	if len(all) != len(tests) {
+		t.Fatalf("got %d servers, want %d", len(all), len(tests))
+	}
+	for i, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			res := all[i]
+			if res.Server != tt.server {
+				t.Fatalf("got server %q", res.Server)
+			}
+			var errText string
+			if res.Err != nil {
+				errText = res.Err.Error()
+			}
+			if errText != tt.err {
+				t.Fatalf("got error %q, want %q", errText, tt.err)
+			}
+			var resources, templates []string
+			for _, r := range res.Resources {
+				resources = append(resources, r.URI)
+			}
+			for _, tmpl := range res.Templates {
+				templates = append(templates, tmpl.URITemplate.Raw())
+			}
+			if strings.Join(resources, ",") != strings.Join(tt.resources, ",") {
+				t.Fatalf("got resources %q, want %q", resources, tt.resources)
+			}
+			if strings.Join(templates, ",") != strings.Join(tt.templates, ",") {
+				t.Fatalf("got templates %q, want %q", templates, tt.templates)
+			}
+		})
+	}
+
+	t.Run("mentions", func(t *testing.T) {
+		tests := []struct {
+			prompt string
+			want   string
+		}{
+			{
+				prompt: "summarize @docs:docs://readme",
+				want:   "summarize @docs:docs://readme\n\n<resource server=\"docs\" uri=\"docs://readme\" mime_type=\"text/plain\">\nhello\n</resource>\n",
+			},
+			{
+				prompt: "@tools:x isn't a resource",
+				want:   "@tools:x isn't a resource",
+			},
+			{
+				prompt: "@missing:x isn't a server",
+				want:   "@missing:x isn't a server",
+			},
+		}
+		for _, tt := range tests {
+			got, err := m.ExpandMentions(context.Background(), tt.prompt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		}
+	})
+
+	t.Run("list_resources", func(t *testing.T) {
+		d := &Driver{Servers: m}
+		var req mcp.CallToolRequest
+		req.Params.Name = "list_resources"
+		res, ok := d.callResourceTool(context.Background(), req)
+		if !ok {
+			t.Fatal("not handled")
+		}
+		want := strings.Join([]string{
+			"Server: broken",
+			"- error: failed to list resources: broken: database is down",
+			"Server: docs",
+			"- docs://readme (readme)  ",
+			"- template: docs://{page} (page)  ",
+			"Server: files",
+			"- file:///a (a)  ",
+			"",
+		}, "\n")
+		if res.IsError || resultText(res) != want {
+			t.Fatalf("got %q, want %q", resultText(res), want)
+		}
+	})
+}
@@ -0,0 +1,136 @@
+package sloppy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+type AnthropicSamplerOptions struct {
+	Client *anthropic.Client
+	// Model defaults to DefaultModel.
+	Model string
+	// MaxTokens caps the number of tokens a server may request.
+	MaxTokens int64
+	// Approve is called before every request is sent to the model.
+	// The request is rejected if it returns false.
+	// All requests are approved when it's nil.
+	Approve func(server string, req *mcp.CreateMessageRequest) bool
+}
+
+// AnthropicSampler handles MCP sampling requests using the Anthropic API.
+type AnthropicSampler struct {
+	client    *anthropic.Client
+	model     string
+	maxTokens int64
+	approve   func(server string, req *mcp.CreateMessageRequest) bool
+}
+
+func NewAnthropicSampler(opt *AnthropicSamplerOptions) *AnthropicSampler {
+	if opt == nil {
+		opt = &AnthropicSamplerOptions{}
+	}
+	if opt.Client == nil {
+		client := anthropic.NewClient()
+		opt.Client = &client
+	}
+	if opt.Model == "" {
+		opt.Model = DefaultModel
+	}
+	if opt.MaxTokens <= 0 {
+		opt.MaxTokens = 1024
+	}
+	return &AnthropicSampler{
+		client:    opt.Client,
+		model:     opt.Model,
+		maxTokens: opt.MaxTokens,
+		approve:   opt.Approve,
+	}
+}
+
+// CreateMessage sends the sampling request from the named server to the model.
+func (s *AnthropicSampler) CreateMessage(ctx context.Context, server string, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error) {
+	if s.approve != nil && !s.approve(server, &req) {
+		return nil, fmt.Errorf("sampling request rejected by user")
+	}
+	maxTokens := s.maxTokens
+	if n := int64(req.Params.MaxTokens); n > 0 && n < maxTokens {
+		maxTokens = n
+	}
+	params := anthropic.MessageNewParams{
+		Model:         anthropic.Model(s.model),
+		MaxTokens:     maxTokens,
+		StopSequences: req.Params.StopSequences,
+	}
+	if req.Params.SystemPrompt != "" {
+		params.System = []anthropic.TextBlockParam{{Text: req.Params.SystemPrompt}}
+	}
+	if req.Params.Temperature > 0 {
+		params.Temperature = anthropic.Float(req.Params.Temperature)
+	}
+	for _, m := range req.Params.Messages {
+		block, err := samplingBlock(m.Content)
+		if err != nil {
+			return nil, err
+		}
+		if m.Role == mcp.RoleAssistant {
+			params.Messages = append(params.Messages, anthropic.NewAssistantMessage(block))
+		} else {
+			params.Messages = append(params.Messages, anthropic.NewUserMessage(block))
+		}
+	}
+	msg, err := s.client.Messages.New(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	var text strings.Builder
+	for _, block := range msg.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return &mcp.CreateMessageResult{
+		SamplingMessage: mcp.SamplingMessage{
+			Role:    mcp.RoleAssistant,
+			Content: mcp.NewTextContent(text.String()),
+		},
+		Model:      string(msg.Model),
+		StopReason: samplingStopReason(msg.StopReason),
+	}, nil
+}
+
+// samplingBlock converts sampling message content into an Anthropic block.
+// The content is decoded as a generic map so it must be parsed first.
+func samplingBlock(content any) (anthropic.ContentBlockParamUnion, error) {
+	if m, ok := content.(map[string]any); ok {
+		parsed, err := mcp.ParseContent(m)
+		if err != nil {
+			return anthropic.ContentBlockParamUnion{}, err
+		}
+		content = parsed
+	}
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return anthropic.NewTextBlock(c.Text), nil
+	case mcp.ImageContent:
+		return anthropic.NewImageBlockBase64(c.MIMEType, c.Data), nil
+	default:
+		return anthropic.ContentBlockParamUnion{}, fmt.Errorf("unsupported sampling content type: %T", content)
+	}
+}
+
+func samplingStopReason(reason anthropic.StopReason) string {
+	switch reason {
+	case anthropic.StopReasonEndTurn:
+		return "endTurn"
+	case anthropic.StopReasonMaxTokens:
+		return "maxTokens"
+	case anthropic.StopReasonStopSequence:
+		return "stopSequence"
+	default:
+		return string(reason)
+	}
+}
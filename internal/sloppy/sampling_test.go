@@ -0,0 +1,80 @@
+package sloppy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestAnthropicSamplerCreateMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxTokens int
+		approve   bool
+		// sent is the max_tokens sent to the model, or 0 if nothing is sent
+		sent float64
+		err  string
+	}{
+		{name: "default", sent: 100},
+		{name: "smaller", maxTokens: 10, sent: 10},
+		{name: "capped", maxTokens: 1000, sent: 100},
+		{name: "denied", maxTokens: 10, approve: true, err: "rejected by user"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests := newFakeClient(t, []any{map[string]any{"type": "text", "text": "sampled"}})
+			var approved []string
+			opts := &AnthropicSamplerOptions{
+				Client:    client,
+				Model:     "test-model",
+				MaxTokens: 100,
+			}
+			if tt.approve {
+				opts.Approve = func(server string, req *mcp.CreateMessageRequest) bool {
+					approved = append(approved, server)
+					return false
+				}
+			}
+			var req mcp.CreateMessageRequest
+			req.Params.MaxTokens = tt.maxTokens
+			req.Params.SystemPrompt = "be brief"
+			req.Params.Messages = []mcp.SamplingMessage{
+				{Role: mcp.RoleUser, Content: map[string]any{"type": "text", "text": "hello"}},
+			}
+			res, err := NewAnthropicSampler(opts).CreateMessage(context.Background(), "github", req)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				if len(approved) != 1 || approved[0] != "github" {
+					t.Fatalf("got approvals %q", approved)
+				}
+				select {
+				case body := <-requests:
+					t.Fatalf("denied request was sent: %v", body)
+				default:
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := <-requests
+			if body["max_tokens"] != tt.sent {
+				t.Fatalf("got max_tokens %v, want %v", body["max_tokens"], tt.sent)
+			}
+			if body["model"] != "test-model" {
+				t.Fatalf("got model %v", body["model"])
+			}
+			text, ok := res.Content.(mcp.TextContent)
+			if !ok || text.Text != "sampled" {
+				t.Fatalf("got content %#v", res.Content)
+			}
+			if res.Role != mcp.RoleAssistant || res.Model != "test-model" || res.StopReason != "endTurn" {
+				t.Fatalf("unexpected result: %+v", res)
+			}
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package sloppy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ServerStatus describes the state of a managed MCP server.
+type ServerStatus string
+
+const (
+	ServerStarting   ServerStatus = "starting"
+	ServerRunning    ServerStatus = "running"
+	ServerRestarting ServerStatus = "restarting"
+	ServerFailed     ServerStatus = "failed"
+	ServerStopped    ServerStatus = "stopped"
+)
+
+// ConnectFunc creates and starts a new client for an MCP server.
+type ConnectFunc func(ctx context.Context) (*client.Client, error)
+
+// ServerInfo is a snapshot of a managed server's state.
+type ServerInfo struct {
+	Name     string
+	Status   ServerStatus
+	Tools    int
+	Restarts int
+	Err      error
+}
+
+type managedServer struct {
+	name    string
+	connect ConnectFunc
+	timeout time.Duration
+	filter  *ToolFilter
+	// refreshing serializes the tool refreshes so that tools listed
+	// before a change can't replace the tools listed after it.
+	refreshing sync.Mutex
+
+	// the following fields are guarded by ServerManager.mu
+	// gen is incremented every time the client is attached or detached
+	// so that stale watchers and restarts can detect that they lost.
+	gen      int
+	client   *client.Client
+	tools    []Tool
+	status   ServerStatus
+	restarts int
+	err      error
+	// prompts caches the server's prompts once they've been listed.
+	// promptsGen is incremented when they change, so that a listing
+	// which started before the change isn't cached.
+	prompts       []mcp.Prompt
+	promptsCached bool
+	promptsGen    int
+}
+
+// ServerManager owns the clients for a set of MCP servers.
+// It watches each server, restarts the ones which die, and closes
+// them all on shutdown.
+type ServerManager struct {
+	// MaxRestarts is the number of consecutive restart attempts
+	// before a server is marked as failed.
+	MaxRestarts int
+	// PingInterval is how often servers are health checked when
+	// their transport can't report when it's closed.
+	PingInterval time.Duration
+	// MaxBackoff is the maximum delay between restart attempts.
+	MaxBackoff time.Duration
+	// RestartTimeout limits how long a restart attempt may take.
+	RestartTimeout time.Duration
+
+	mu      sync.Mutex
+	servers []*managedServer
+	closed  bool
+	done    chan struct{}
+}
+
+// NewServerManager returns a ServerManager with the default settings.
+func NewServerManager() *ServerManager {
+	return &ServerManager{
+		MaxRestarts:    5,
+		PingInterval:   30 * time.Second,
+		MaxBackoff:     30 * time.Second,
+		RestartTimeout: 30 * time.Second,
+		done:           make(chan struct{}),
+	}
+}
+
+// Server describes an MCP server to be managed.
+type Server struct {
+	Name    string
+	Connect ConnectFunc
+	// Timeout limits how long the server may take to start.
+	// Zero means no limit.
+	Timeout time.Duration
+	// Filter, when set, is applied to the server's tools.
+	Filter *ToolFilter
+}
+
+// Add connects to a new server and starts watching it.
+// The server is retained even if the initial connection fails
+// so that it can be restarted manually.
+func (m *ServerManager) Add(ctx context.Context, name string, connect ConnectFunc) error {
+	return m.Start(ctx, Server{Name: name, Connect: connect})[0]
+}
+
+// Start connects to the servers concurrently and starts watching them.
+// The returned slice contains the error, if any, for the server at the
+// same index. Servers are retained even if their initial connection fails
+// so that they can be restarted manually.
+func (m *ServerManager) Start(ctx context.Context, servers ...Server) []error {
+	errs := make([]error, len(servers))
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		for i := range errs {
+			errs[i] = fmt.Errorf("server manager is closed")
+		}
+		return errs
+	}
+	managed := make([]*managedServer, len(servers))
+	for i, srv := range servers {
+		managed[i] = &managedServer{
+			name:    srv.Name,
+			connect: srv.Connect,
+			timeout: srv.Timeout,
+			filter:  srv.Filter,
+			status:  ServerStarting,
+		}
+	}
+	m.servers = append(m.servers, managed...)
+	m.mu.Unlock()
+	var wg sync.WaitGroup
+	for i, s := range managed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, tools, err := m.open(ctx, s)
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if s.gen != 0 || m.closed {
+				if c != nil {
+					c.Close()
+				}
+				return
+			}
+			if err == nil {
+				err = m.conflict(s, c, tools)
+			}
+			if err != nil {
+				s.status = ServerFailed
+				s.err = err
+				errs[i] = fmt.Errorf("%s: %w", s.name, err)
+				return
+			}
+			m.attach(s, c, tools)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// Tools returns the tools from all running servers.
+func (m *ServerManager) Tools() []Tool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var tools []Tool
+	for _, s := range m.servers {
+		tools = append(tools, s.tools...)
+	}
+	return tools
+}
+
+// Status returns a snapshot of each server's state.
+func (m *ServerManager) Status() []ServerInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var infos []ServerInfo
+	for _, s := range m.servers {
+		infos = append(infos, ServerInfo{
+			Name:     s.name,
+			Status:   s.status,
+			Tools:    len(s.tools),
+			Restarts: s.restarts,
+			Err:      s.err,
+		})
+	}
+	return infos
+}
+
+// Restart closes the named server's client and connects again.
+func (m *ServerManager) Restart(ctx context.Context, name string) error {
+	m.mu.Lock()
+	s := m.find(name)
+	if s == nil {
+		m.mu.Unlock()
+		return fmt.Errorf("server not found: %q", name)
+	}
+	old := m.detach(s, ServerRestarting, nil)
+	gen := s.gen
+	m.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	c, tools, err := m.open(ctx, s)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.gen != gen || m.closed {
+		if c != nil {
+			c.Close()
+		}
+		return fmt.Errorf("%s: restart interrupted", name)
+	}
+	if err == nil {
+		err = m.conflict(s, c, tools)
+	}
+	if err != nil {
+		s.status = ServerFailed
+		s.err = err
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	s.restarts++
+	m.attach(s, c, tools)
+	return nil
+}
+
+// Close stops watching the servers and closes their clients.
+func (m *ServerManager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.done)
+	var clients []*client.Client
+	for _, s := range m.servers {
+		if c := m.detach(s, ServerStopped, nil); c != nil {
+			clients = append(clients, c)
+		}
+	}
+	m.mu.Unlock()
+	var errs []error
+	for _, c := range clients {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// open connects to the server, initializes the client and lists its tools.
+func (m *ServerManager) open(ctx context.Context, s *managedServer) (*client.Client, []Tool, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	c, err := s.connect(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create mcp client: %w", err)
+	}
+	// failed clients are closed in the background because an
+	// unresponsive server can take a while to shut down.
+	var init mcp.InitializeRequest
+	init.Params.Capabilities = c.GetClientCapabilities()
+	if _, err := c.Initialize(ctx, init); err != nil {
+		go c.Close()
+		return nil, nil, fmt.Errorf("failed to initialize mcp client: %w", err)
+	}
+	// servers which only provide resources or prompts have no tools
+	if c.GetServerCapabilities().Tools == nil {
+		return c, nil, nil
+	}
+	tools, err := ListClientTools(ctx, s.name, c)
+	if err != nil {
+		go c.Close()
+		return nil, nil, fmt.Errorf("failed to list mcp tools: %w", err)
+	}
+	if s.filter != nil {
+		tools, err = s.filter.Apply(tools)
+		if err != nil {
+			go c.Close()
+			return nil, nil, err
+		}
+	}
+	return c, tools, nil
+}
+
+// attach makes c the server's active client and starts watching it.
+// The caller must hold m.mu.
+func (m *ServerManager) attach(s *managedServer, c *client.Client, tools []Tool) {
+	s.gen++
+	s.client = c
+	s.tools = tools
+	s.status = ServerRunning
+	s.err = nil
+	gen := s.gen
+	c.OnNotification(func(n mcp.JSONRPCNotification) {
+		switch n.Method {
+		case mcp.MethodNotificationToolsListChanged:
+			// the handler is called from the transport's read loop,
+			// so the tools must be listed from another goroutine.
+			go m.refresh(s, gen, c)
+		case mcp.MethodNotificationPromptsListChanged:
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if s.gen == gen {
+				s.prompts = nil
+				s.promptsCached = false
+				s.promptsGen++
+			}
+		}
+	})
+	go m.watch(s, gen, c)
+}
+
+// refresh re-lists the server's tools after they have changed.
+func (m *ServerManager) refresh(s *managedServer, gen int, c *client.Client) {
+	s.refreshing.Lock()
+	defer s.refreshing.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), m.RestartTimeout)
+	defer cancel()
+	tools, err := ListClientTools(ctx, s.name, c)
+	if err == nil && s.filter != nil {
+		tools, err = s.filter.Apply(tools)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.gen != gen || m.closed {
+		return
+	}
+	if err == nil {
+		err = m.conflict(s, nil, tools)
+	}
+	if err != nil {
+		s.err = fmt.Errorf("failed to refresh mcp tools: %w", err)
+		return
+	}
+	s.tools = tools
+}
+
+// conflict returns an error if one of the tools has the same alias as
+// a tool from another server. The client, if any, is closed when there's
+// a conflict. The caller must hold m.mu.
+func (m *ServerManager) conflict(s *managedServer, c *client.Client, tools []Tool) error {
+	for _, other := range m.servers {
+		if other == s {
+			continue
+		}
+		for _, t := range other.tools {
+			for _, tool := range tools {
+				if t.Alias == tool.Alias {
+					if c != nil {
+						go c.Close()
+					}
+					return fmt.Errorf("tool %q conflicts with a tool from %s", tool.Alias, other.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// detach removes the server's active client and returns it.
+// The caller must hold m.mu.
+func (m *ServerManager) detach(s *managedServer, status ServerStatus, err error) *client.Client {
+	s.gen++
+	c := s.client
+	s.client = nil
+	s.tools = nil
+	s.prompts = nil
+	s.promptsCached = false
+	s.status = status
+	s.err = err
+	return c
+}
+
+func (m *ServerManager) find(name string) *managedServer {
+	for _, s := range m.servers {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// closeNotifier is implemented by transports which can report when
+// the connection has been lost.
+type closeNotifier interface {
+	Done() <-chan struct{}
+	Err() error
+}
+
+// watch waits for the client's transport to die and then restarts it.
+func (m *ServerManager) watch(s *managedServer, gen int, c *client.Client) {
+	var closed <-chan struct{}
+	notifier, ok := c.GetTransport().(closeNotifier)
+	if ok {
+		closed = notifier.Done()
+	}
+	ticker := time.NewTicker(m.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-closed:
+			err := notifier.Err()
+			if err == nil {
+				err = fmt.Errorf("server exited")
+			}
+			m.recover(s, gen, err)
+			return
+		case <-ticker.C:
+			if !m.current(s, gen) {
+				return
+			}
+			if ok {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), m.PingInterval)
+			err := c.Ping(ctx)
+			cancel()
+			if err != nil {
+				m.recover(s, gen, fmt.Errorf("ping failed: %w", err))
+				return
+			}
+		}
+	}
+}
+
+// current reports whether gen is still the server's active generation.
+func (m *ServerManager) current(s *managedServer, gen int) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return s.gen == gen && !m.closed
+}
+
+// recover restarts a dead server with exponential backoff.
+func (m *ServerManager) recover(s *managedServer, gen int, cause error) {
+	m.mu.Lock()
+	if s.gen != gen || m.closed {
+		m.mu.Unlock()
+		return
+	}
+	old := m.detach(s, ServerRestarting, cause)
+	gen = s.gen
+	m.mu.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	backoff := min(time.Second, m.MaxBackoff)
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-m.done:
+			return
+		case <-time.After(backoff):
+		}
+		if !m.current(s, gen) {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), m.RestartTimeout)
+		c, tools, err := m.open(ctx, s)
+		cancel()
+		m.mu.Lock()
+		if s.gen != gen || m.closed {
+			m.mu.Unlock()
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err == nil {
+			err = m.conflict(s, c, tools)
+		}
+		if err == nil {
+			s.restarts++
+			m.attach(s, c, tools)
+			m.mu.Unlock()
+			return
+		}
+		s.err = err
+		if attempt >= m.MaxRestarts {
+			s.status = ServerFailed
+			m.mu.Unlock()
+			return
+		}
+		m.mu.Unlock()
+		backoff = min(backoff*2, m.MaxBackoff)
+	}
+}
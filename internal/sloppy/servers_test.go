@@ -0,0 +1,301 @@
+package sloppy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TestMain runs a stdio MCP server instead of the tests when
+// SLOPPY_TEST_SERVER_TOOL is set. The server has a single tool
+// with the variable's value as its name.
+func TestMain(m *testing.M) {
+	if name := os.Getenv("SLOPPY_TEST_SERVER_TOOL"); name != "" {
+		s := server.NewMCPServer("test", "1.0.0")
+		s.AddTool(mcp.NewTool(name), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("ok"), nil
+		})
+		if err := server.ServeStdio(s); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// commandServer starts the test binary as a stdio MCP server. The tool
+// of the nth server to start is named tool<n>, starting from tool0.
+type commandServer struct {
+	mu       sync.Mutex
+	cmds     []*exec.Cmd
+	attempts int
+	err      error
+}
+
+func (s *commandServer) Connect(ctx context.Context) (*client.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts++
+	if s.err != nil {
+		return nil, s.err
+	}
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), fmt.Sprintf("SLOPPY_TEST_SERVER_TOOL=tool%d", len(s.cmds)))
+	c, err := mcpx.NewCommandClient(ctx, cmd, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.cmds = append(s.cmds, cmd)
+	return c, nil
+}
+
+// Kill kills the most recently started server.
+func (s *commandServer) Kill(t *testing.T) {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.cmds[len(s.cmds)-1].Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Fail makes the following connections fail with err.
+func (s *commandServer) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Started returns the number of servers started and connection attempts.
+func (s *commandServer) Started() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cmds), s.attempts
+}
+
+// Exited reports whether the nth server has exited.
+// It must only be called once the server's client has been closed.
+func (s *commandServer) Exited(n int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmds[n].ProcessState != nil
+}
+
+// newTestManager returns a server manager which restarts servers quickly.
+func newTestManager(t *testing.T) *ServerManager {
+	t.Helper()
+	m := NewServerManager()
+	m.MaxBackoff = 10 * time.Millisecond
+	m.PingInterval = 10 * time.Millisecond
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// waitStatus waits for the named server's state to match f
+// and returns it.
+func waitStatus(t *testing.T, m *ServerManager, name string, f func(info ServerInfo) bool) ServerInfo {
+	t.Helper()
+	var info ServerInfo
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		for _, i := range m.Status() {
+			if i.Name == name {
+				info = i
+			}
+		}
+		if f(info) {
+			return info
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %s: %+v", name, info)
+	return info
+}
+
+// toolAliases returns the sorted aliases of the manager's tools.
+func toolAliases(m *ServerManager) []string {
+	var aliases []string
+	for _, tool := range m.Tools() {
+		aliases = append(aliases, tool.Alias)
+	}
+	slices.Sort(aliases)
+	return aliases
+}
+
+func TestServerManagerRestartOnExit(t *testing.T) {
+	m := newTestManager(t)
+	var s commandServer
+	if err := m.Add(context.Background(), "cmd", s.Connect); err != nil {
+		t.Fatal(err)
+	}
+	if got := toolAliases(m); !slices.Equal(got, []string{"cmd-tool0"}) {
+		t.Fatalf("got tools %q", got)
+	}
+	s.Kill(t)
+	info := waitStatus(t, m, "cmd", func(info ServerInfo) bool {
+		return info.Status == ServerRunning && info.Restarts == 1
+	})
+	if info.Err != nil {
+		t.Fatalf("unexpected error: %v", info.Err)
+	}
+	// the tools are listed again from the new server
+	if got := toolAliases(m); !slices.Equal(got, []string{"cmd-tool1"}) {
+		t.Fatalf("got tools %q", got)
+	}
+}
+
+func TestServerManagerMaxRestarts(t *testing.T) {
+	m := newTestManager(t)
+	m.MaxRestarts = 3
+	var s commandServer
+	if err := m.Add(context.Background(), "cmd", s.Connect); err != nil {
+		t.Fatal(err)
+	}
+	s.Fail(errors.New("connection refused"))
+	s.Kill(t)
+	info := waitStatus(t, m, "cmd", func(info ServerInfo) bool {
+		return info.Status == ServerFailed
+	})
+	if info.Err == nil || info.Restarts != 0 || info.Tools != 0 {
+		t.Fatalf("unexpected status: %+v", info)
+	}
+	if _, attempts := s.Started(); attempts != 1+m.MaxRestarts {
+		t.Fatalf("got %d connection attempts, want %d", attempts, 1+m.MaxRestarts)
+	}
+	if tools := m.Tools(); len(tools) != 0 {
+		t.Fatalf("failed server has %d tools", len(tools))
+	}
+}
+
+func TestServerManagerManualRestart(t *testing.T) {
+	m := newTestManager(t)
+	var s commandServer
+	if err := m.Add(context.Background(), "cmd", s.Connect); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Restart(context.Background(), "cmd"); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exited(0) {
+		t.Fatal("the old server is still running")
+	}
+	info := waitStatus(t, m, "cmd", func(info ServerInfo) bool { return true })
+	if info.Status != ServerRunning || info.Restarts != 1 {
+		t.Fatalf("unexpected status: %+v", info)
+	}
+	if got := toolAliases(m); !slices.Equal(got, []string{"cmd-tool1"}) {
+		t.Fatalf("got tools %q", got)
+	}
+	if err := m.Restart(context.Background(), "missing"); err == nil {
+		t.Fatal("expected an error for an unknown server")
+	}
+}
+
+func TestServerManagerRestartFailed(t *testing.T) {
+	m := newTestManager(t)
+	var s commandServer
+	s.Fail(errors.New("connection refused"))
+	if err := m.Add(context.Background(), "cmd", s.Connect); err == nil {
+		t.Fatal("expected the first connection to fail")
+	}
+	// failed servers are kept so that they can be restarted
+	s.Fail(nil)
+	if err := m.Restart(context.Background(), "cmd"); err != nil {
+		t.Fatal(err)
+	}
+	if got := toolAliases(m); !slices.Equal(got, []string{"cmd-tool0"}) {
+		t.Fatalf("got tools %q", got)
+	}
+}
+
+func TestServerManagerPing(t *testing.T) {
+	m := newTestManager(t)
+	var mu sync.Mutex
+	var connects int
+	err := m.Add(context.Background(), "fake", func(ctx context.Context) (*client.Client, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		connects++
+		// the first server stops answering pings
+		ping := any(map[string]any{})
+		if connects == 1 {
+			ping = testError("unavailable")
+		}
+		c := client.NewClient(fakeResourceServer(map[string]any{"ping": ping}))
+		return c, c.Start(ctx)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, m, "fake", func(info ServerInfo) bool {
+		return info.Status == ServerRunning && info.Restarts == 1
+	})
+}
+
+func TestServerManagerClose(t *testing.T) {
+	m := newTestManager(t)
+	var s commandServer
+	if err := m.Add(context.Background(), "cmd", s.Connect); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Exited(0) {
+		t.Fatal("the server is still running")
+	}
+	info := waitStatus(t, m, "cmd", func(info ServerInfo) bool { return true })
+	if info.Status != ServerStopped {
+		t.Fatalf("got status %s, want %s", info.Status, ServerStopped)
+	}
+	// the watcher mustn't restart the closed server
+	time.Sleep(10 * m.MaxBackoff)
+	if started, _ := s.Started(); started != 1 {
+		t.Fatalf("got %d servers started, want 1", started)
+	}
+	if errs := m.Start(context.Background(), Server{Name: "late", Connect: s.Connect}); errs[0] == nil {
+		t.Fatal("expected an error when starting a server after close")
+	}
+}
+
+func TestServerManagerRefresh(t *testing.T) {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	noop := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	s.AddTool(mcp.NewTool("tool0"), noop)
+	m := newTestServers(t, s)
+	var want []string
+	want = append(want, "test-tool0")
+	// each change sends a notification, the last refresh must win
+	for i := 1; i <= 10; i++ {
+		s.AddTool(mcp.NewTool(fmt.Sprintf("tool%d", i)), noop)
+		want = append(want, fmt.Sprintf("test-tool%d", i))
+	}
+	slices.Sort(want)
+	var got []string
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		got = got[:0]
+		for _, tool := range m.Tools() {
+			got = append(got, tool.Alias)
+		}
+		slices.Sort(got)
+		if slices.Equal(got, want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("got tools %v, want %v", got, want)
+}
@@ -3,14 +3,21 @@ package sloppy
 import (
 	"context"
 	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
 
+	"github.com/icholy/sloppy/internal/mcpx"
 	"github.com/mark3labs/mcp-go/client"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
 type Tool struct {
-	Alias  string
-	Tool   mcp.Tool
+	Alias string
+	Tool  mcp.Tool
+	// Schema is the input schema as sent by the server.
+	Schema map[string]any
 	Client *client.Client
 }
 
@@ -20,18 +27,129 @@ func (t Tool) ToAlias() mcp.Tool {
 	return tool
 }
 
+// InputSchema returns the schema used to validate the tool's arguments.
+func (t Tool) InputSchema() map[string]any {
+	if t.Schema != nil {
+		return t.Schema
+	}
+	return mcpx.SchemaMap(t.Tool.InputSchema)
+}
+
+// toolNameRe matches the tool names accepted by the Messages API.
+var toolNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// reservedToolNames are the tools which are provided by the driver.
+var reservedToolNames = []string{"run_agent", "list_resources", "read_resource"}
+
+// ValidateToolName returns an error if name can't be used as the name of a server's tool.
+func ValidateToolName(name string) error {
+	if !toolNameRe.MatchString(name) {
+		return fmt.Errorf("invalid tool name: %q: must match %s", name, toolNameRe)
+	}
+	if slices.Contains(reservedToolNames, name) {
+		return fmt.Errorf("invalid tool name: %q: reserved for a built-in tool", name)
+	}
+	return nil
+}
+
 func ListClientTools(ctx context.Context, name string, c *client.Client) ([]Tool, error) {
 	var tools []Tool
-	res, err := c.ListTools(ctx, mcp.ListToolsRequest{})
+	list, schemas, err := mcpx.ListTools(ctx, c)
 	if err != nil {
 		return nil, err
 	}
-	for _, t := range res.Tools {
+	for _, t := range list {
 		tools = append(tools, Tool{
 			Alias:  fmt.Sprintf("%s-%s", name, t.Name),
 			Tool:   t,
+			Schema: schemas[t.Name],
 			Client: c,
 		})
 	}
 	return tools, nil
 }
+
+// ToolFilter selects, renames, and re-describes the tools from a server.
+// Tools are matched using their original names.
+type ToolFilter struct {
+	// Include is a list of glob patterns. If it's not empty, only
+	// the matching tools are kept.
+	Include []string
+	// Exclude is a list of glob patterns. Matching tools are removed.
+	Exclude []string
+	// Aliases maps tool names to the alias exposed to the model.
+	Aliases map[string]string
+	// Descriptions maps tool names to replacement descriptions.
+	Descriptions map[string]string
+}
+
+// Validate checks that the aliases are valid tool names and that
+// no two tools have the same alias.
+func (f *ToolFilter) Validate() error {
+	seen := map[string]string{}
+	for _, name := range slices.Sorted(maps.Keys(f.Aliases)) {
+		alias := f.Aliases[name]
+		if err := ValidateToolName(alias); err != nil {
+			return fmt.Errorf("invalid alias for tool %q: %w", name, err)
+		}
+		if other, ok := seen[alias]; ok {
+			return fmt.Errorf("tools %q and %q have the same alias: %q", other, name, alias)
+		}
+		seen[alias] = name
+	}
+	return nil
+}
+
+// Apply returns the filtered tools.
+// It returns an error if two of the remaining tools have the same alias.
+func (f *ToolFilter) Apply(tools []Tool) ([]Tool, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	var filtered []Tool
+	seen := map[string]string{}
+	for _, t := range tools {
+		name := t.Tool.Name
+		if len(f.Include) > 0 {
+			ok, err := matchAny(f.Include, name)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		ok, err := matchAny(f.Exclude, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+		if alias, ok := f.Aliases[name]; ok {
+			t.Alias = alias
+		}
+		if desc, ok := f.Descriptions[name]; ok {
+			t.Tool.Description = desc
+		}
+		if other, ok := seen[t.Alias]; ok {
+			return nil, fmt.Errorf("tools %q and %q have the same alias: %q", other, name, t.Alias)
+		}
+		seen[t.Alias] = name
+		filtered = append(filtered, t)
+	}
+	return filtered, nil
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("invalid tool pattern: %q: %w", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
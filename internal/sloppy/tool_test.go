@@ -0,0 +1,164 @@
+package sloppy
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestValidateToolName(t *testing.T) {
+	tests := []struct {
+		name string
+		err  bool
+	}{
+		{name: "github-get_me"},
+		{name: "A1_b-2"},
+		{name: strings.Repeat("a", 64)},
+		{name: "", err: true},
+		{name: strings.Repeat("a", 65), err: true},
+		{name: "has space", err: true},
+		{name: "dotted.name", err: true},
+		{name: "run_agent", err: true},
+		{name: "list_resources", err: true},
+		{name: "read_resource", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateToolName(tt.name); (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestToolFilterApply(t *testing.T) {
+	var tools []Tool
+	for _, name := range []string{"get_me", "get_issue", "create_issue", "delete_repo"} {
+		tools = append(tools, Tool{
+			Alias: "github-" + name,
+			Tool:  mcp.NewTool(name, mcp.WithDescription("original")),
+		})
+	}
+	tests := []struct {
+		name    string
+		filter  ToolFilter
+		aliases []string
+		descs   map[string]string
+		err     string
+	}{
+		{
+			name:    "empty",
+			aliases: []string{"github-get_me", "github-get_issue", "github-create_issue", "github-delete_repo"},
+		},
+		{
+			name:    "include",
+			filter:  ToolFilter{Include: []string{"get_*"}},
+			aliases: []string{"github-get_me", "github-get_issue"},
+		},
+		{
+			name:    "exclude",
+			filter:  ToolFilter{Exclude: []string{"delete_*", "create_*"}},
+			aliases: []string{"github-get_me", "github-get_issue"},
+		},
+		{
+			name:    "include and exclude",
+			filter:  ToolFilter{Include: []string{"*_issue"}, Exclude: []string{"create_*"}},
+			aliases: []string{"github-get_issue"},
+		},
+		{
+			name:    "alias",
+			filter:  ToolFilter{Include: []string{"get_me"}, Aliases: map[string]string{"get_me": "whoami"}},
+			aliases: []string{"whoami"},
+		},
+		{
+			name:    "alias of excluded tool",
+			filter:  ToolFilter{Include: []string{"get_me"}, Aliases: map[string]string{"get_issue": "github-get_me"}},
+			aliases: []string{"github-get_me"},
+		},
+		{
+			name:    "description",
+			filter:  ToolFilter{Include: []string{"get_*"}, Descriptions: map[string]string{"get_me": "Who am I?"}},
+			aliases: []string{"github-get_me", "github-get_issue"},
+			descs:   map[string]string{"github-get_me": "Who am I?", "github-get_issue": "original"},
+		},
+		{
+			name:   "invalid pattern",
+			filter: ToolFilter{Include: []string{"["}},
+			err:    "invalid tool pattern",
+		},
+		{
+			name:   "invalid alias",
+			filter: ToolFilter{Aliases: map[string]string{"get_me": "who am i"}},
+			err:    "invalid tool name",
+		},
+		{
+			name:   "reserved alias",
+			filter: ToolFilter{Aliases: map[string]string{"get_me": "run_agent"}},
+			err:    "reserved",
+		},
+		{
+			name:   "duplicate aliases",
+			filter: ToolFilter{Aliases: map[string]string{"get_me": "me", "get_issue": "me"}},
+			err:    "same alias",
+		},
+		{
+			name:   "alias conflicts with tool",
+			filter: ToolFilter{Aliases: map[string]string{"get_me": "github-get_issue"}},
+			err:    "same alias",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filtered, err := tt.filter.Apply(tools)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var aliases []string
+			for _, tool := range filtered {
+				aliases = append(aliases, tool.Alias)
+				if want, ok := tt.descs[tool.Alias]; ok && tool.Tool.Description != want {
+					t.Fatalf("%s: got description %q, want %q", tool.Alias, tool.Tool.Description, want)
+				}
+			}
+			if !slices.Equal(aliases, tt.aliases) {
+				t.Fatalf("got %q, want %q", aliases, tt.aliases)
+			}
+		})
+	}
+	// the input tools mustn't be modified
+	if tools[0].Alias != "github-get_me" || tools[0].Tool.Description != "original" {
+		t.Fatalf("input tools were modified: %+v", tools[0])
+	}
+}
+
+func TestServerManagerToolConflict(t *testing.T) {
+	noop := func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("ok"), nil
+	}
+	a := server.NewMCPServer("a", "1.0.0")
+	a.AddTool(mcp.NewTool("echo"), noop)
+	b := server.NewMCPServer("b", "1.0.0")
+	b.AddTool(mcp.NewTool("say"), noop)
+	m := newTestServers(t, a)
+	errs := m.Start(context.Background(), Server{
+		Name:    "b",
+		Connect: inProcessConnect(b),
+		Filter:  &ToolFilter{Aliases: map[string]string{"say": "test-echo"}},
+	})
+	if errs[0] == nil || !strings.Contains(errs[0].Error(), "conflicts") {
+		t.Fatalf("got error %v, want a conflict", errs[0])
+	}
+	if got := len(m.Tools()); got != 1 {
+		t.Fatalf("got %d tools, want 1", got)
+	}
+}
@@ -1,19 +1,40 @@
 package termcolor
 
+import (
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
 // ANSI color escape codes
 const (
-	Reset  = "\u001b[0m"
-	Red    = "\u001b[31m"
-	Green  = "\u001b[32m"
-	Yellow = "\u001b[33m"
-	Blue   = "\u001b[34m"
-	Purple = "\u001b[35m"
-	Cyan   = "\u001b[36m"
-	White  = "\u001b[37m"
-	Bold   = "\u001b[1m"
+	Reset     = "\u001b[0m"
+	Red       = "\u001b[31m"
+	Green     = "\u001b[32m"
+	Yellow    = "\u001b[33m"
+	Blue      = "\u001b[34m"
+	Purple    = "\u001b[35m"
+	Cyan      = "\u001b[36m"
+	White     = "\u001b[37m"
+	Gray      = "\u001b[90m"
+	Bold      = "\u001b[1m"
+	Dim       = "\u001b[2m"
+	Italic    = "\u001b[3m"
+	Underline = "\u001b[4m"
 )
 
 // Text wraps text in the specified color and resets afterward
 func Text(text string, color string) string {
 	return color + text + Reset
-}
\ No newline at end of file
+}
+
+// Enabled reports whether colors should be written to w.
+// Colors are disabled when w is not a terminal or NO_COLOR is set.
+func Enabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -1,99 +1,500 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"encoding/json"
-	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
-	"syscall"
+	"sync"
 
 	"github.com/icholy/sloppy/internal/builtin"
+	"github.com/icholy/sloppy/internal/readline"
 	"github.com/icholy/sloppy/internal/sloppy"
 	"github.com/icholy/sloppy/internal/termcolor"
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "api":
+			api(os.Args[2:])
+			return
+		}
+	}
+	setup := newSetup(flag.CommandLine, "ask")
 	var prompt string
-	var configPath string
-	var useBuiltin bool
-	flag.StringVar(&configPath, "config", "", "configuration file")
-	flag.BoolVar(&useBuiltin, "builtin", true, "use built-in tools")
-	flag.StringVar(&prompt, "prompt", "", "use this prompt and then exit")
+	flag.StringVar(&prompt, "prompt", "", "use this prompt and then exit (- reads it from stdin)")
+	flag.StringVar(&prompt, "p", "", "shorthand for -prompt")
+	var promptFile string
+	flag.StringVar(&promptFile, "prompt-file", "", "read the prompt from this file and then exit")
+	var historyFile string
+	flag.StringVar(&historyFile, "history", defaultHistoryFile(), "REPL history file (empty disables history)")
+	var output string
+	var maxTurns int
+	flag.StringVar(&output, "output", "text", "output format for -prompt (text, json, or jsonl)")
+	flag.IntVar(&maxTurns, "max-turns", 0, "maximum number of agent turns per prompt (0 means no limit)")
 	flag.Parse()
+	prompt, err := readPrompt(prompt, promptFile, os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	switch output {
+	case "text":
+	case "json", "jsonl":
+		if prompt == "" {
+			log.Fatalf("-output=%s requires -prompt", output)
+		}
+	default:
+		log.Fatalf("invalid -output value: %q", output)
+	}
 	var driver sloppy.Driver
+	driver.MaxTurns = maxTurns
 	ctx := context.Background()
-	if configPath != "" {
-		config, err := ReadConfig(configPath)
+	servers := sloppy.NewServerManager()
+	defer servers.Close()
+	driver.Servers = servers
+	collector := &promptCollector{}
+	// structured output is the only thing written to stdout, so
+	// commands are shown on stderr and can't read the piped input
+	providers := builtinProviders(os.Stderr, nil)
+	switch output {
+	case "json":
+		driver.Sink = collector
+	case "jsonl":
+		driver.Sink = sloppy.MultiSink(sloppy.NewJSONLSink(os.Stdout), collector)
+	default:
+		driver.Sink = sloppy.NewTerminalSink(os.Stdout)
+		providers = builtinProviders(os.Stdout, os.Stdin)
+	}
+	// sampling requests are only approved by asking on the terminal,
+	// so they're denied when the output is structured
+	approver := &samplingApprover{}
+	config, err := setup.Start(ctx, servers, providers, approver.Approve)
+	if err != nil {
+		log.Fatal(err)
+	}
+	driver.Hooks = config.DriverHooks()
+	driver.NewAgent = setup.NewAgent
+	if prompt != "" {
+		if output == "text" {
+			editor, err := readline.New(nil)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer editor.Close()
+			approver.SetEditor(editor)
+		}
+		err := driver.Loop(ctx, prompt)
+		servers.Close()
+		if output != "text" {
+			res := collector.Result(err)
+			if err := writePromptResult(output, res); err != nil {
+				log.Fatal(err)
+			}
+			os.Exit(res.ExitCode)
+		}
 		if err != nil {
-			log.Fatal(err)
+			log.Print(err)
+			_, code := exitStatus(err)
+			os.Exit(code)
+		}
+		return
+	}
+	templates, err := LoadTemplateCommands(templateDirs()...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := newREPL(&driver, servers, templates)
+	editor, err := readline.New(&readline.Options{
+		Prompt:      colorize("You", termcolor.Blue) + ": ",
+		HistoryFile: historyFile,
+		Complete:    r.complete,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer editor.Close()
+	approver.SetEditor(editor)
+	r.Run(ctx, editor)
+}
+
+// readPrompt returns the prompt for a non-interactive run, or an empty
+// string for the REPL. The prompt is read from stdin if it's "-", and from
+// promptFile if it's set. When stdin is piped, it's either attached to the
+// prompt as context, or used as the prompt if there isn't one.
+func readPrompt(prompt, promptFile string, stdin *os.File) (string, error) {
+	if promptFile != "" {
+		if prompt != "" {
+			return "", fmt.Errorf("-prompt and -prompt-file cannot be used together")
 		}
-		tools, err := config.ListTools(ctx)
+		data, err := os.ReadFile(promptFile)
 		if err != nil {
-			log.Fatal(err)
+			return "", fmt.Errorf("failed to read prompt file: %w", err)
+		}
+		prompt = string(data)
+		if strings.TrimSpace(prompt) == "" {
+			return "", fmt.Errorf("prompt file is empty: %s", promptFile)
 		}
-		driver.Tools = append(driver.Tools, tools...)
 	}
-	if useBuiltin {
-		tools := builtin.Tools("builtin",
-			&builtin.RunCommand{},
-			&builtin.ApplyDiff{Threshold: 0.9},
-			&builtin.WriteFile{},
-			&builtin.ReadFile{},
-		)
-		driver.Tools = append(driver.Tools, tools...)
+	if prompt != "-" && !isPiped(stdin) {
+		return prompt, nil
 	}
-	driver.NewAgent = func(name string) sloppy.Agent {
-		return sloppy.NewAnthropicAgent(&sloppy.AnthropicAgentOptions{
-			Name: name,
-		})
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
 	}
-	if prompt != "" {
-		if err := driver.Loop(ctx, prompt); err != nil {
-			log.Fatal(err)
+	input := string(data)
+	if prompt != "" && prompt != "-" {
+		if strings.TrimSpace(input) == "" {
+			return prompt, nil
 		}
-		return
+		if !strings.HasSuffix(input, "\n") {
+			input += "\n"
+		}
+		return prompt + "\n\n<stdin>\n" + input + "</stdin>", nil
 	}
-	fmt.Println("Tell sloppy what to do")
-	scanner := bufio.NewScanner(os.Stdin)
-	for {
-		fmt.Printf("%s: ", termcolor.Text("You", termcolor.Blue))
-		if !scanner.Scan() {
-			break
-		}
-		text := scanner.Text()
-		switch strings.TrimSpace(text) {
-		case "/tools":
-			for i, t := range driver.Tools {
-				if i > 0 {
-					fmt.Println()
-				}
-				data, _ := json.MarshalIndent(t.Tool.InputSchema, "", "  ")
-				fmt.Printf("Tool: %s\nDescription: %s\nSchema: %s\n",
-					t.Alias,
-					t.Tool.Description,
-					data,
-				)
+	if strings.TrimSpace(input) == "" {
+		return "", fmt.Errorf("no prompt provided on stdin")
+	}
+	return input, nil
+}
+
+// isPiped reports whether f is a pipe or a regular file. Terminals, devices
+// and sockets aren't treated as input because they may never reach EOF.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeNamedPipe != 0 || info.Mode().IsRegular()
+}
+
+// SamplingFunc handles a sampling request from the named server.
+type SamplingFunc func(ctx context.Context, server string, req mcp.CreateMessageRequest) (*mcp.CreateMessageResult, error)
+
+// newSampling returns the sampling handler for the -sampling flag value.
+// The approve function is used when the mode is "ask".
+// It returns nil if sampling is denied.
+func newSampling(mode, model string, maxTokens int64, approve func(string, *mcp.CreateMessageRequest) bool) (SamplingFunc, error) {
+	opts := &sloppy.AnthropicSamplerOptions{
+		Model:     model,
+		MaxTokens: maxTokens,
+	}
+	switch mode {
+	case "ask":
+		opts.Approve = approve
+	case "allow":
+	case "deny":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("invalid -sampling value: %q", mode)
+	}
+	return sloppy.NewAnthropicSampler(opts).CreateMessage, nil
+}
+
+// loadConfig reads the config file. It returns an empty
+// config if the path is empty.
+func loadConfig(configPath string) (*Config, error) {
+	if configPath == "" {
+		return &Config{}, nil
+	}
+	return ReadConfig(configPath)
+}
+
+// startServers starts the MCP servers in the config.
+func startServers(ctx context.Context, servers *sloppy.ServerManager, config *Config, sampling SamplingFunc) error {
+	config.Sampling = sampling
+	if err := config.AddServers(ctx, servers); err != nil {
+		return err
+	}
+	for _, info := range servers.Status() {
+		if info.Status == sloppy.ServerRunning {
+			log.Printf("%s: loaded %d tools", info.Name, info.Tools)
+		}
+	}
+	return nil
+}
+
+// builtinProviders returns all of the built-in tools. The output of
+// commands is copied to output, and they read their input from stdin.
+func builtinProviders(output io.Writer, stdin io.Reader) []builtin.ToolProvider {
+	return []builtin.ToolProvider{
+		&builtin.RunCommand{Output: output, Stdin: stdin},
+		&builtin.ApplyDiff{Threshold: 0.9},
+		&builtin.WriteFile{},
+		&builtin.ReadFile{},
+	}
+}
+
+// startBuiltin starts the in-process server with the selected built-in
+// tools and the custom tools. Custom tools are always enabled.
+func startBuiltin(ctx context.Context, servers *sloppy.ServerManager, f *builtinFlag, providers []builtin.ToolProvider, custom []*builtin.CommandTool) error {
+	if !f.enabled && len(custom) == 0 {
+		return nil
+	}
+	providers = slices.Clone(providers)
+	filter, err := f.Filter(providers)
+	if err != nil {
+		return err
+	}
+	for _, t := range custom {
+		for _, p := range providers {
+			if p.ServerTool().Tool.Name == t.Name {
+				return fmt.Errorf("custom tool conflicts with built-in tool: %s", t.Name)
 			}
+		}
+	}
+	if !f.enabled {
+		providers = nil
+	}
+	for _, t := range custom {
+		providers = append(providers, t)
+		if filter != nil {
+			filter.Include = append(filter.Include, t.Name)
+		}
+	}
+	errs := servers.Start(ctx, sloppy.Server{
+		Name: "builtin",
+		Connect: func(ctx context.Context) (*client.Client, error) {
+			return builtin.NewClient("builtin", providers...)
+		},
+		Filter: filter,
+	})
+	return errs[0]
+}
+
+// templateDirs returns the directories which contain user-defined
+// commands. Commands in the project directory take precedence.
+func templateDirs() []string {
+	dirs := []string{filepath.Join(".sloppy", "commands")}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".sloppy", "commands"))
+	}
+	return dirs
+}
+
+// defaultHistoryFile returns the path of the history file in the home directory.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".sloppy_history")
+}
+
+// promptCommand runs a /server:prompt arg=value command. The prompt's
+// messages are added to the current agent's conversation.
+func promptCommand(ctx context.Context, driver *sloppy.Driver, servers *sloppy.ServerManager, text string) error {
+	command, rest, _ := strings.Cut(strings.TrimPrefix(strings.TrimSpace(text), "/"), " ")
+	server, name, _ := strings.Cut(command, ":")
+	fields, err := splitArgs(rest)
+	if err != nil {
+		return err
+	}
+	args := map[string]string{}
+	for _, f := range fields {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			return fmt.Errorf("invalid prompt argument: %q: expected name=value", f)
+		}
+		args[k] = v
+	}
+	prompts, err := servers.Prompts(ctx)
+	if err != nil {
+		return err
+	}
+	var prompt *mcp.Prompt
+	for _, sp := range prompts {
+		if sp.Server != server {
 			continue
-		case "/clear":
-			driver.Stack = []sloppy.Frame{}
-			continue
-		case "/stack":
-			for _, frame := range driver.Stack {
-				fmt.Println(frame.Name)
+		}
+		for _, p := range sp.Prompts {
+			if p.Name == name {
+				prompt = &p
+			}
+		}
+	}
+	if prompt == nil {
+		return fmt.Errorf("unknown prompt: /%s:%s", server, name)
+	}
+	for _, arg := range prompt.Arguments {
+		if _, ok := args[arg.Name]; arg.Required && !ok {
+			return fmt.Errorf("missing required prompt argument: %q", arg.Name)
+		}
+	}
+	res, err := servers.GetPrompt(ctx, server, name, args)
+	if err != nil {
+		return err
+	}
+	if len(res.Messages) == 0 {
+		return fmt.Errorf("prompt has no messages: /%s:%s", server, name)
+	}
+	return driver.LoopInput(ctx, &sloppy.RunInput{Messages: res.Messages})
+}
+
+// splitArgs splits s on whitespace. Double quotes may be used
+// to include whitespace in an argument.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var b strings.Builder
+	var quoted, inArg bool
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		default:
+			b.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, b.String())
+	}
+	return args, nil
+}
+
+// readAttachments reads the named image and PDF files.
+func readAttachments(names []string) ([]sloppy.Attachment, error) {
+	var atts []sloppy.Attachment
+	for _, name := range names {
+		att, err := sloppy.ReadAttachment(name)
+		if err != nil {
+			return nil, err
+		}
+		atts = append(atts, *att)
+	}
+	return atts, nil
+}
+
+// samplingApprover asks the user whether a server may use the model.
+// The answer is read using the editor, and requests are denied when
+// there's no terminal to ask on.
+type samplingApprover struct {
+	mu     sync.Mutex
+	editor *readline.Editor
+}
+
+// SetEditor sets the editor used to read answers.
+func (a *samplingApprover) SetEditor(editor *readline.Editor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.editor = editor
+}
+
+func (a *samplingApprover) Approve(server string, req *mcp.CreateMessageRequest) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.editor == nil || !a.editor.IsTerminal() {
+		log.Printf("WARNING: denied sampling request from %s: stdin is not a terminal", server)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s: %s wants to sample the model (max tokens: %d)\n",
+		colorize("Sampling", termcolor.Purple), server, req.Params.MaxTokens)
+	if req.Params.SystemPrompt != "" {
+		fmt.Fprintf(os.Stderr, "  system: %s\n", truncate(req.Params.SystemPrompt, 200))
+	}
+	for _, m := range req.Params.Messages {
+		data, _ := json.Marshal(m.Content)
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", m.Role, truncate(string(data), 200))
+	}
+	answer, err := a.editor.Prompt("Allow? [y/N]: ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// colorize colors the text if stdout supports colors.
+func colorize(text, color string) string {
+	if !termcolor.Enabled(os.Stdout) {
+		return text
+	}
+	return termcolor.Text(text, color)
+}
+
+// truncate shortens s to n runes.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n]) + "..."
+}
+
+// builtinFlag selects which built-in tools are enabled.
+// It accepts true, false, or a comma separated list of tool names.
+type builtinFlag struct {
+	enabled bool
+	names   []string
+}
+
+func (f *builtinFlag) IsBoolFlag() bool { return true }
+
+func (f *builtinFlag) String() string {
+	if f == nil || !f.enabled {
+		return "false"
+	}
+	if len(f.names) > 0 {
+		return strings.Join(f.names, ",")
+	}
+	return "true"
+}
+
+func (f *builtinFlag) Set(s string) error {
+	f.names = nil
+	switch s {
+	case "true", "all":
+		f.enabled = true
+	case "false", "none", "":
+		f.enabled = false
+	default:
+		f.enabled = true
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				f.names = append(f.names, name)
 			}
-			continue
 		}
-		ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT)
-		if err := driver.Loop(ctx, text); err != nil && !errors.Is(err, context.Canceled) {
-			log.Printf("ERROR: %s", err)
+	}
+	return nil
+}
+
+// Filter returns a tool filter which only includes the selected tools.
+// It returns an error if a selected tool doesn't exist.
+func (f *builtinFlag) Filter(providers []builtin.ToolProvider) (*sloppy.ToolFilter, error) {
+	if len(f.names) == 0 {
+		return nil, nil
+	}
+	var available []string
+	for _, p := range providers {
+		available = append(available, p.ServerTool().Tool.Name)
+	}
+	for _, name := range f.names {
+		if !slices.Contains(available, name) {
+			return nil, fmt.Errorf("unknown built-in tool: %q (available: %s)", name, strings.Join(available, ", "))
 		}
-		stop()
 	}
+	// the filter is extended with the custom tools, so it gets a copy
+	return &sloppy.ToolFilter{Include: slices.Clone(f.names)}, nil
 }
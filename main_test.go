@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when SLOPPY_TEST_MAIN is set,
+// so that the tests can run sloppy as a subprocess.
+func TestMain(m *testing.M) {
+	if os.Getenv("SLOPPY_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs sloppy with the arguments and returns its stdout,
+// stderr and exit code.
+func runMain(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "SLOPPY_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), cmd.ProcessState.ExitCode()
+}
+
+func TestBuiltinFlagFilter(t *testing.T) {
+	tests := []struct {
+		value   string
+		include []string
+		err     bool
+	}{
+		{value: "true"},
+		{value: "false"},
+		{value: "read_file,write_file", include: []string{"read_file", "write_file"}},
+		{value: "read_file,missing", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			var f builtinFlag
+			if err := f.Set(tt.value); err != nil {
+				t.Fatal(err)
+			}
+			filter, err := f.Filter(builtinProviders(nil, nil))
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err {
+				return
+			}
+			if tt.include == nil {
+				if filter != nil {
+					t.Fatalf("got filter %+v", filter)
+				}
+				return
+			}
+			if !slices.Equal(filter.Include, tt.include) {
+				t.Fatalf("got %q, want %q", filter.Include, tt.include)
+			}
+			// extending the filter mustn't change the flag
+			names := slices.Clone(f.names)
+			filter.Include = append(filter.Include, "custom")
+			filter.Include[0] = "changed"
+			if !slices.Equal(f.names, names) {
+				t.Fatalf("flag was modified: %q", f.names)
+			}
+		})
+	}
+}
+
+func TestReadPrompt(t *testing.T) {
+	dir := t.TempDir()
+	promptFile := filepath.Join(dir, "prompt.md")
+	if err := os.WriteFile(promptFile, []byte("from file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty.md")
+	if err := os.WriteFile(emptyFile, []byte(" \n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// pipe, file, and device return the file used as stdin
+	pipe := func(data string) func(t *testing.T) *os.File {
+		return func(t *testing.T) *os.File {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { r.Close() })
+			go func() {
+				w.WriteString(data)
+				w.Close()
+			}()
+			return r
+		}
+	}
+	file := func(data string) func(t *testing.T) *os.File {
+		return func(t *testing.T) *os.File {
+			name := filepath.Join(t.TempDir(), "stdin")
+			if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { f.Close() })
+			return f
+		}
+	}
+	device := func(t *testing.T) *os.File {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	tests := []struct {
+		name       string
+		prompt     string
+		promptFile string
+		stdin      func(t *testing.T) *os.File
+		want       string
+		err        string
+	}{
+		{name: "repl", stdin: device, want: ""},
+		{name: "prompt", prompt: "hello", stdin: device, want: "hello"},
+		{name: "stdin prompt", prompt: "-", stdin: pipe("from stdin\n"), want: "from stdin\n"},
+		{name: "empty stdin prompt", prompt: "-", stdin: pipe(" \n"), err: "no prompt provided on stdin"},
+		{name: "stdin prompt from device", prompt: "-", stdin: device, err: "no prompt provided on stdin"},
+		{name: "piped prompt", stdin: pipe("from stdin"), want: "from stdin"},
+		{name: "piped context", prompt: "fix this", stdin: pipe("log"), want: "fix this\n\n<stdin>\nlog\n</stdin>"},
+		{name: "redirected context", prompt: "fix this", stdin: file("log\n"), want: "fix this\n\n<stdin>\nlog\n</stdin>"},
+		{name: "empty context", prompt: "fix this", stdin: pipe(""), want: "fix this"},
+		{name: "prompt file", promptFile: promptFile, stdin: device, want: "from file\n"},
+		{name: "prompt file context", promptFile: promptFile, stdin: pipe("log\n"), want: "from file\n\n\n<stdin>\nlog\n</stdin>"},
+		{name: "prompt file and prompt", prompt: "hello", promptFile: promptFile, stdin: device, err: "cannot be used together"},
+		{name: "prompt file and stdin prompt", prompt: "-", promptFile: promptFile, stdin: device, err: "cannot be used together"},
+		{name: "empty prompt file", promptFile: emptyFile, stdin: device, err: "prompt file is empty"},
+		{name: "missing prompt file", promptFile: filepath.Join(dir, "missing.md"), stdin: device, err: "failed to read prompt file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readPrompt(tt.prompt, tt.promptFile, tt.stdin(t))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Exit codes for -prompt runs.
+const (
+	exitModelError = 3
+	exitToolError  = 4 // a tool call failed, see sloppy.ToolError
+	exitMaxTurns   = 5
+	exitBlocked    = 6
+)
+
+// exitStatus returns the status and exit code for the error returned by the driver.
+func exitStatus(err error) (string, int) {
+	var toolErr *sloppy.ToolError
+	var blockedErr *sloppy.HookBlockedError
+	switch {
+	case err == nil:
+		return "success", 0
+	case errors.Is(err, sloppy.ErrMaxTurns):
+		return "max_turns", exitMaxTurns
+	case errors.As(err, &toolErr):
+		return "tool_error", exitToolError
+	case errors.As(err, &blockedErr):
+		return "blocked", exitBlocked
+	default:
+		return "model_error", exitModelError
+	}
+}
+
+// promptToolCall is a tool call and its result.
+type promptToolCall struct {
+	Agent     string              `json:"agent"`
+	Tool      string              `json:"tool"`
+	Arguments any                 `json:"arguments,omitempty"`
+	Result    *mcp.CallToolResult `json:"result,omitempty"`
+}
+
+// promptResult is the final record written by the json and jsonl output modes.
+type promptResult struct {
+	Type      string           `json:"type"`
+	Status    string           `json:"status"`
+	ExitCode  int              `json:"exit_code"`
+	Result    string           `json:"result"`
+	Error     string           `json:"error,omitempty"`
+	Turns     int              `json:"turns"`
+	Usage     sloppy.Usage     `json:"usage"`
+	ToolCalls []promptToolCall `json:"tool_calls,omitempty"`
+}
+
+// promptCollector is an event sink which builds the promptResult.
+type promptCollector struct {
+	mu     sync.Mutex
+	result promptResult
+	text   []string
+}
+
+func (c *promptCollector) Event(e sloppy.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch e.Type {
+	case sloppy.EventTurnStart:
+		// only the text from the final turn is the result
+		c.result.Turns++
+		c.text = nil
+	case sloppy.EventText:
+		c.text = append(c.text, e.Text)
+	case sloppy.EventUsage:
+		c.result.Usage.Add(*e.Usage)
+	case sloppy.EventToolCall:
+		c.result.ToolCalls = append(c.result.ToolCalls, promptToolCall{
+			Agent:     e.Agent,
+			Tool:      e.Tool,
+			Arguments: e.Arguments,
+		})
+	case sloppy.EventToolResult:
+		if call := c.pending(func(call *promptToolCall) bool {
+			return call.Agent == e.Agent && call.Tool == e.Tool
+		}); call != nil {
+			call.Result = e.Result
+		}
+	case sloppy.EventAgentFinish:
+		if call := c.pending(func(call *promptToolCall) bool {
+			args, _ := call.Arguments.(map[string]any)
+			return call.Tool == "run_agent" && args["name"] == e.Agent
+		}); call != nil {
+			call.Result = mcp.NewToolResultText(e.Text)
+		}
+	}
+}
+
+// pending returns the most recent tool call without a result which matches f.
+func (c *promptCollector) pending(f func(call *promptToolCall) bool) *promptToolCall {
+	for i := len(c.result.ToolCalls) - 1; i >= 0; i-- {
+		call := &c.result.ToolCalls[i]
+		if call.Result == nil && f(call) {
+			return call
+		}
+	}
+	return nil
+}
+
+// Result returns the final record for the driver error.
+func (c *promptCollector) Result(err error) promptResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := c.result
+	res.Type = "result"
+	res.Status, res.ExitCode = exitStatus(err)
+	if err != nil {
+		res.Error = err.Error()
+	} else {
+		res.Result = strings.Join(c.text, "\n")
+	}
+	return res
+}
+
+// writePromptResult writes the result to stdout in the output format.
+// Tool calls are only included in the json format because the jsonl
+// format has already written them as events.
+func writePromptResult(format string, res promptResult) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	switch format {
+	case "json":
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case "jsonl":
+		res.ToolCalls = nil
+		return enc.Encode(res)
+	default:
+		return fmt.Errorf("invalid output format: %q", format)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status string
+		code   int
+	}{
+		{name: "success", err: nil, status: "success", code: 0},
+		{name: "max turns", err: sloppy.ErrMaxTurns, status: "max_turns", code: exitMaxTurns},
+		{
+			name:   "tool error",
+			err:    &sloppy.ToolError{Tool: "test-echo", Err: errors.New("transport closed")},
+			status: "tool_error",
+			code:   exitToolError,
+		},
+		{
+			name:   "wrapped tool error",
+			err:    fmt.Errorf("agent: %w", &sloppy.ToolError{Tool: "test-echo", Err: errors.New("timeout")}),
+			status: "tool_error",
+			code:   exitToolError,
+		},
+		{name: "blocked", err: &sloppy.HookBlockedError{Message: "no"}, status: "blocked", code: exitBlocked},
+		{name: "model error", err: errors.New("overloaded"), status: "model_error", code: exitModelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := exitStatus(tt.err)
+			if status != tt.status || code != tt.code {
+				t.Fatalf("got (%q, %d), want (%q, %d)", status, code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestPromptOutputCommand(t *testing.T) {
+	tests := []string{"json", "jsonl"}
+	for _, output := range tests {
+		t.Run(output, func(t *testing.T) {
+			newScriptedAnthropic(t,
+				[]any{map[string]any{
+					"type":  "tool_use",
+					"id":    "toolu_1",
+					"name":  "builtin-run_command",
+					"input": map[string]any{"command": "echo from-command"},
+				}},
+				[]any{map[string]any{"type": "text", "text": "done"}},
+			)
+			stdout, stderr, code := runMain(t, "-p", "run it", "-output", output, "-builtin=run_command")
+			if code != 0 {
+				t.Fatalf("exit code %d: %s", code, stderr)
+			}
+			// every line of stdout must be json
+			var res map[string]any
+			dec := json.NewDecoder(strings.NewReader(stdout))
+			for dec.More() {
+				res = nil
+				if err := dec.Decode(&res); err != nil {
+					t.Fatalf("invalid output: %v: %s", err, stdout)
+				}
+			}
+			if res["type"] != "result" || res["result"] != "done" {
+				t.Fatalf("unexpected result: %v", res)
+			}
+			if calls, _ := res["tool_calls"].([]any); output == "json" && len(calls) != 1 {
+				t.Fatalf("got %d tool calls, want 1", len(calls))
+			}
+			if !strings.Contains(stderr, "from-command") {
+				t.Fatalf("command output wasn't shown on stderr: %s", stderr)
+			}
+		})
+	}
+}
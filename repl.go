@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/icholy/sloppy/internal/readline"
+	"github.com/icholy/sloppy/internal/sloppy"
+)
+
+// repl is the interactive prompt loop.
+type repl struct {
+	driver   *sloppy.Driver
+	servers  *sloppy.ServerManager
+	commands Commands
+	// attachments are sent with the next prompt
+	attachments []sloppy.Attachment
+}
+
+func newREPL(driver *sloppy.Driver, servers *sloppy.ServerManager, templates []*TemplateCommand) *repl {
+	r := &repl{driver: driver, servers: servers}
+	commands := []*Command{
+		{
+			Name:    "help",
+			Usage:   "[command]",
+			Help:    "Show the available commands.",
+			MaxArgs: 1,
+			Run:     r.help,
+			Complete: func(args []string) []string {
+				if len(args) != 1 {
+					return nil
+				}
+				var names []string
+				for _, cmd := range r.commands.List() {
+					names = append(names, cmd.Name)
+				}
+				return readline.CompleteWords(args[0], names)
+			},
+		},
+		{
+			Name:    "attach",
+			Usage:   "[path...]",
+			Help:    "Attach images or PDFs to the next prompt, or list the attachments.",
+			MaxArgs: -1,
+			Run:     r.attach,
+			Complete: func(args []string) []string {
+				return readline.CompletePath(args[len(args)-1])
+			},
+		},
+		{
+			Name: "clear",
+			Help: "Clear the conversation.",
+			Run: func(ctx context.Context, args []string) error {
+				r.driver.Stack = []sloppy.Frame{}
+				r.attachments = nil
+				return nil
+			},
+		},
+		{
+			Name: "stack",
+			Help: "Show the agent stack.",
+			Run: func(ctx context.Context, args []string) error {
+				for _, frame := range r.driver.Stack {
+					fmt.Println(frame.Name)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "tools",
+			Help: "Show the available tools.",
+			Run:  r.tools,
+		},
+		{
+			Name: "prompts",
+			Help: "Show the prompts provided by MCP servers.",
+			Run:  r.prompts,
+		},
+		{
+			Name:    "mcp",
+			Usage:   "[restart <server>]",
+			Help:    "Show the MCP server status, or restart a server.",
+			MaxArgs: 2,
+			Run:     r.mcp,
+			Complete: func(args []string) []string {
+				switch len(args) {
+				case 1:
+					return readline.CompleteWords(args[0], []string{"restart"})
+				case 2:
+					var names []string
+					for _, info := range r.servers.Status() {
+						names = append(names, info.Name)
+					}
+					return readline.CompleteWords(args[1], names)
+				default:
+					return nil
+				}
+			},
+		},
+	}
+	for _, cmd := range commands {
+		r.commands.Add(cmd)
+	}
+	for _, t := range templates {
+		err := r.commands.Add(&Command{
+			Name:    t.Name,
+			Usage:   "[arguments]",
+			Help:    t.Description,
+			MaxArgs: -1,
+			Run: func(ctx context.Context, args []string) error {
+				return r.prompt(ctx, t.Expand(args))
+			},
+		})
+		if err != nil {
+			log.Printf("WARNING: %s", err)
+		}
+	}
+	return r
+}
+
+// Run reads and handles input until there is no more.
+func (r *repl) Run(ctx context.Context, editor *readline.Editor) {
+	fmt.Println("Tell sloppy what to do")
+	for {
+		text, err := editor.ReadLine()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: %s", err)
+			return
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT)
+		err = r.handle(ctx, text)
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("ERROR: %s", err)
+		}
+	}
+}
+
+// handle runs a slash command or sends the text as a prompt.
+func (r *repl) handle(ctx context.Context, text string) error {
+	if !strings.HasPrefix(text, "/") {
+		return r.prompt(ctx, text)
+	}
+	if r.isPromptCommand(text) {
+		return promptCommand(ctx, r.driver, r.servers, text)
+	}
+	// paths like /src/main.go:12 are sent to the agent
+	if strings.Contains(strings.Fields(text)[0], ":") {
+		return r.prompt(ctx, text)
+	}
+	return r.commands.Run(ctx, text)
+}
+
+// isPromptCommand reports whether the text is a /server:prompt command
+// for one of the configured servers.
+func (r *repl) isPromptCommand(text string) bool {
+	command := strings.TrimPrefix(strings.Fields(text)[0], "/")
+	server, _, ok := strings.Cut(command, ":")
+	if !ok {
+		return false
+	}
+	for _, info := range r.servers.Status() {
+		if info.Name == server {
+			return true
+		}
+	}
+	return false
+}
+
+// prompt sends the text to the agent along with the pending attachments,
+// @path attachments, and @server:uri resources.
+func (r *repl) prompt(ctx context.Context, text string) error {
+	var paths []string
+	for _, path := range sloppy.AttachmentMentions(text) {
+		// @server:uri mentions are resources
+		if server, _, ok := strings.Cut(path, ":"); ok {
+			if _, ok := r.servers.Client(server); ok {
+				continue
+			}
+		}
+		paths = append(paths, path)
+	}
+	mentioned, err := readAttachments(paths)
+	if err != nil {
+		return err
+	}
+	text, err = r.servers.ExpandMentions(ctx, text)
+	if err != nil {
+		return err
+	}
+	input := &sloppy.RunInput{
+		Prompt:      text,
+		Attachments: append(r.attachments, mentioned...),
+	}
+	r.attachments = nil
+	return r.driver.LoopInput(ctx, input)
+}
+
+// complete returns the tab completion candidates for the line.
+func (r *repl) complete(line string) []string {
+	start := strings.LastIndex(line, " ") + 1
+	word := line[start:]
+	if strings.HasPrefix(word, "@") {
+		var paths []string
+		for _, path := range readline.CompletePath(word[1:]) {
+			paths = append(paths, "@"+path)
+		}
+		return paths
+	}
+	if !strings.HasPrefix(line, "/") {
+		return nil
+	}
+	candidates := r.commands.Complete(line)
+	if start == 0 {
+		var names []string
+		// the prompts are cached, but listing them the first time
+		// mustn't block the editor if a server is unresponsive
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if prompts, err := r.servers.Prompts(ctx); err == nil {
+			for _, sp := range prompts {
+				for _, p := range sp.Prompts {
+					names = append(names, "/"+sp.Server+":"+p.Name)
+				}
+			}
+		}
+		candidates = append(candidates, readline.CompleteWords(word, names)...)
+	}
+	return candidates
+}
+
+func (r *repl) help(ctx context.Context, args []string) error {
+	if len(args) == 1 {
+		cmd, ok := r.commands.Lookup(strings.TrimPrefix(args[0], "/"))
+		if !ok {
+			return fmt.Errorf("unknown command: /%s", args[0])
+		}
+		fmt.Printf("%s\n  %s\n", cmd.Synopsis(), cmd.Help)
+		return nil
+	}
+	for _, cmd := range r.commands.List() {
+		fmt.Printf("%-28s %s\n", cmd.Synopsis(), cmd.Help)
+	}
+	fmt.Printf("%-28s %s\n", "/server:prompt [name=value]", "Run a prompt provided by an MCP server.")
+	return nil
+}
+
+func (r *repl) attach(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		for _, att := range r.attachments {
+			fmt.Printf("%s (%s, %d bytes)\n", att.Name, att.MIMEType, len(att.Data))
+		}
+		return nil
+	}
+	atts, err := readAttachments(args)
+	if err != nil {
+		return err
+	}
+	for _, att := range atts {
+		fmt.Printf("attached %s (%s, %d bytes)\n", att.Name, att.MIMEType, len(att.Data))
+	}
+	r.attachments = append(r.attachments, atts...)
+	return nil
+}
+
+func (r *repl) tools(ctx context.Context, args []string) error {
+	for i, t := range r.servers.Tools() {
+		if i > 0 {
+			fmt.Println()
+		}
+		data, _ := json.MarshalIndent(t.Tool.InputSchema, "", "  ")
+		fmt.Printf("Tool: %s\nDescription: %s\nSchema: %s\n",
+			t.Alias,
+			t.Tool.Description,
+			data,
+		)
+	}
+	return nil
+}
+
+func (r *repl) prompts(ctx context.Context, args []string) error {
+	prompts, err := r.servers.Prompts(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sp := range prompts {
+		for _, p := range sp.Prompts {
+			var args []string
+			for _, arg := range p.Arguments {
+				if arg.Required {
+					args = append(args, arg.Name+"=<value>")
+				} else {
+					args = append(args, "["+arg.Name+"=<value>]")
+				}
+			}
+			fmt.Printf("/%s:%s %s\n", sp.Server, p.Name, strings.Join(args, " "))
+			if p.Description != "" {
+				fmt.Printf("  %s\n", p.Description)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *repl) mcp(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		for _, info := range r.servers.Status() {
+			fmt.Printf("%s: %s (tools: %d, restarts: %d)\n", info.Name, info.Status, info.Tools, info.Restarts)
+			if info.Err != nil {
+				fmt.Printf("  error: %v\n", info.Err)
+			}
+		}
+		return nil
+	}
+	if args[0] != "restart" || len(args) != 2 {
+		return fmt.Errorf("usage: /mcp [restart <server>]")
+	}
+	if err := r.servers.Restart(ctx, args[1]); err != nil {
+		return err
+	}
+	fmt.Printf("restarted %s\n", args[1])
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/client"
+)
+
+func TestREPLIsPromptCommand(t *testing.T) {
+	servers := sloppy.NewServerManager()
+	defer servers.Close()
+	// servers are known even if they fail to start
+	servers.Add(context.Background(), "github", func(ctx context.Context) (*client.Client, error) {
+		return nil, errors.New("offline")
+	})
+	r := newREPL(&sloppy.Driver{}, servers, nil)
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "/github:review-pr number=42", want: true},
+		{text: "/github:review-pr", want: true},
+		{text: "/gitlab:review-pr", want: false},
+		{text: "/home/me/main.go:12 fix this", want: false},
+		{text: "/help", want: false},
+	}
+	for _, tt := range tests {
+		if got := r.isPromptCommand(tt.text); got != tt.want {
+			t.Errorf("isPromptCommand(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/icholy/sloppy/internal/builtin"
+	"github.com/icholy/sloppy/internal/mcpx"
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// serve runs sloppy as an MCP server which exposes a run_task tool.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	setup := newSetup(fs, "deny")
+	var addr string
+	var exportBuiltin bool
+	fs.BoolVar(&exportBuiltin, "export-builtin", false, "also expose the enabled built-in tools to clients")
+	fs.StringVar(&addr, "http", "", "serve over HTTP (SSE) on this address instead of stdio")
+	fs.Parse(args)
+	ctx := context.Background()
+	servers := sloppy.NewServerManager()
+	defer servers.Close()
+	// stdout and stdin may be used by the stdio transport, so commands
+	// are shown on stderr and get no input
+	providers := builtinProviders(os.Stderr, nil)
+	config, err := setup.Start(ctx, servers, providers, nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hooks := config.DriverHooks()
+	var exported []builtin.ToolProvider
+	if exportBuiltin && setup.builtin.enabled {
+		for _, p := range providers {
+			if len(setup.builtin.names) == 0 || slices.Contains(setup.builtin.names, p.ServerTool().Tool.Name) {
+				exported = append(exported, p)
+			}
+		}
+	}
+	s := builtin.NewServer("sloppy", false, exported...)
+	s.AddTool(runTaskTool(), func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return runTask(ctx, servers, hooks, setup.NewAgent, req)
+	})
+	if addr != "" {
+		log.Printf("serving MCP over HTTP at http://%s/sse", addr)
+		err = server.NewSSEServer(s).Start(addr)
+	} else {
+		err = server.ServeStdio(s)
+	}
+	if err != nil {
+		servers.Close()
+		log.Fatal(err)
+	}
+}
+
+func runTaskTool() mcp.Tool {
+	return mcp.NewTool("run_task",
+		mcp.WithDescription("Run a task using the sloppy agent. The agent has access to its own tools and returns its final message once the task is complete."),
+		mcp.WithString("prompt",
+			mcp.Required(),
+			mcp.Description("Instructions for the agent. Only the final response message is returned, so ask for all of the relevant information to be included in it."),
+		),
+	)
+}
+
+// runTask runs the prompt in a new driver. Events are printed to stderr
+// because stdout may be used by the stdio transport.
+func runTask(ctx context.Context, servers *sloppy.ServerManager, hooks []sloppy.Hook, newAgent func(name string) sloppy.Agent, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Prompt string `param:"prompt,required"`
+	}
+	if err := mcpx.MapArguments(req.Params.Arguments, &args); err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to parse arguments", err), nil
+	}
+	collector := &promptCollector{}
+	driver := sloppy.Driver{
+		Servers:  servers,
+		Sink:     sloppy.MultiSink(sloppy.NewTerminalSink(os.Stderr), collector),
+		Hooks:    hooks,
+		NewAgent: newAgent,
+	}
+	if err := driver.Loop(ctx, args.Prompt); err != nil {
+		return mcp.NewToolResultErrorFromErr("failed to run task", err), nil
+	}
+	// the agent's last message is the raw API response, so the
+	// text from the final turn is collected from the events
+	return mcp.NewToolResultText(collector.Result(nil).Result), nil
+}
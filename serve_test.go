@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newFakeAnthropic returns a server which answers every
+// message request with the text.
+func newFakeAnthropic(t *testing.T, text string) {
+	t.Helper()
+	newScriptedAnthropic(t, []any{map[string]any{"type": "text", "text": text}})
+}
+
+// newScriptedAnthropic returns a server which answers the message requests
+// with each of the contents in turn. The last content is repeated.
+func newScriptedAnthropic(t *testing.T, contents ...[]any) {
+	t.Helper()
+	var mu sync.Mutex
+	var n int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		content := contents[min(n, len(contents)-1)]
+		n++
+		mu.Unlock()
+		stop := "end_turn"
+		for _, c := range content {
+			if c.(map[string]any)["type"] == "tool_use" {
+				stop = "tool_use"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":          "msg_1",
+			"type":        "message",
+			"role":        "assistant",
+			"model":       sloppy.DefaultModel,
+			"stop_reason": stop,
+			"content":     content,
+			"usage":       map[string]any{"input_tokens": 1, "output_tokens": 1},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("ANTHROPIC_BASE_URL", ts.URL)
+	t.Setenv("ANTHROPIC_API_KEY", "test")
+}
+
+func TestRunTask(t *testing.T) {
+	newFakeAnthropic(t, "the answer")
+	servers := sloppy.NewServerManager()
+	defer servers.Close()
+	tests := []struct {
+		name    string
+		args    map[string]any
+		text    string
+		isError bool
+	}{
+		{
+			name: "final text",
+			args: map[string]any{"prompt": "what is the answer?"},
+			text: "the answer",
+		},
+		{
+			name:    "missing prompt",
+			args:    map[string]any{},
+			text:    `failed to parse arguments: missing required parameter "prompt"`,
+			isError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req mcp.CallToolRequest
+			req.Params.Name = "run_task"
+			req.Params.Arguments = tt.args
+			res, err := runTask(context.Background(), servers, nil, (&setup{model: sloppy.DefaultModel}).NewAgent, req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.IsError != tt.isError {
+				t.Fatalf("got IsError %v, want %v", res.IsError, tt.isError)
+			}
+			if got := res.Content[0].(mcp.TextContent).Text; got != tt.text {
+				t.Fatalf("got %q, want %q", got, tt.text)
+			}
+		})
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	newScriptedAnthropic(t,
+		[]any{map[string]any{
+			"type":  "tool_use",
+			"id":    "toolu_1",
+			"name":  "builtin-run_command",
+			"input": map[string]any{"command": "echo from-task"},
+		}},
+		[]any{map[string]any{"type": "text", "text": "done"}},
+	)
+	cmd := exec.Command(os.Args[0], "serve", "-builtin=run_command", "-export-builtin")
+	cmd.Env = append(os.Environ(), "SLOPPY_TEST_MAIN=1")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+	lines := bufio.NewScanner(stdout)
+	// call sends the request and returns the result of its response.
+	// Every line written to stdout must be a JSON-RPC message.
+	var id int
+	call := func(method string, params any) map[string]any {
+		t.Helper()
+		id++
+		data, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+		fmt.Fprintf(stdin, "%s\n", data)
+		for lines.Scan() {
+			var msg struct {
+				ID     int            `json:"id"`
+				Result map[string]any `json:"result"`
+			}
+			if err := json.Unmarshal(lines.Bytes(), &msg); err != nil {
+				t.Fatalf("invalid message on stdout: %q", lines.Text())
+			}
+			if msg.ID == id {
+				return msg.Result
+			}
+		}
+		t.Fatalf("no response to %s", method)
+		return nil
+	}
+	call("initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test", "version": "1.0.0"},
+	})
+	fmt.Fprintf(stdin, "%s\n", `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	tests := []struct {
+		tool string
+		args map[string]any
+		text string
+	}{
+		{tool: "run_task", args: map[string]any{"prompt": "run it"}, text: "done"},
+		// the command mustn't read the transport's stdin
+		{tool: "run_command", args: map[string]any{"command": "cat; echo from-command"}, text: "from-command\n"},
+	}
+	for _, tt := range tests {
+		res := call("tools/call", map[string]any{"name": tt.tool, "arguments": tt.args})
+		content, _ := res["content"].([]any)
+		if len(content) == 0 {
+			t.Fatalf("%s: no content: %v", tt.tool, res)
+		}
+		if text := content[0].(map[string]any)["text"]; text != tt.text {
+			t.Fatalf("%s: got %q, want %q", tt.tool, text, tt.text)
+		}
+	}
+	stdin.Close()
+	cmd.Wait()
+	for _, want := range []string{"from-task", "from-command"} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Fatalf("command output %q wasn't shown on stderr: %s", want, stderr.String())
+		}
+	}
+}
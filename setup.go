@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/icholy/sloppy/internal/builtin"
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// setup is the startup shared by the REPL, serve, and api modes.
+type setup struct {
+	mode              string
+	configPath        string
+	builtin           builtinFlag
+	sampling          string
+	samplingMaxTokens int64
+	model             string
+}
+
+// newSetup registers the shared flags on fs. Sampling requests can only be
+// approved by asking on a terminal, so the "ask" mode is only offered when
+// it's the default.
+func newSetup(fs *flag.FlagSet, sampling string) *setup {
+	s := &setup{
+		mode:    fs.Name(),
+		builtin: builtinFlag{enabled: true},
+	}
+	modes := "allow or deny"
+	if sampling == "ask" {
+		modes = "ask, allow, or deny"
+	}
+	fs.StringVar(&s.configPath, "config", "", "configuration file")
+	fs.Var(&s.builtin, "builtin", "use built-in tools (true, false, or a comma separated list of tool names)")
+	fs.StringVar(&s.sampling, "sampling", sampling, "how to handle MCP sampling requests ("+modes+")")
+	fs.Int64Var(&s.samplingMaxTokens, "sampling-max-tokens", 1024, "maximum tokens for MCP sampling requests")
+	fs.StringVar(&s.model, "model", sloppy.DefaultModel, "model used by the agents and for MCP sampling")
+	return s
+}
+
+// Start loads the config and starts its servers and the built-in tools.
+// Sampling requests are approved using approve in the "ask" mode, which is
+// rejected when approve is nil.
+func (s *setup) Start(ctx context.Context, servers *sloppy.ServerManager, providers []builtin.ToolProvider, approve func(string, *mcp.CreateMessageRequest) bool) (*Config, error) {
+	if s.sampling == "ask" && approve == nil {
+		return nil, fmt.Errorf("-sampling=ask is not supported by %s", s.mode)
+	}
+	sampling, err := newSampling(s.sampling, s.model, s.samplingMaxTokens, approve)
+	if err != nil {
+		return nil, err
+	}
+	config, err := loadConfig(s.configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := startServers(ctx, servers, config, sampling); err != nil {
+		return nil, err
+	}
+	if err := startBuiltin(ctx, servers, &s.builtin, providers, config.CommandTools()); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// NewAgent returns an agent which uses the selected model.
+func (s *setup) NewAgent(name string) sloppy.Agent {
+	return sloppy.NewAnthropicAgent(&sloppy.AnthropicAgentOptions{
+		Name:  name,
+		Model: s.model,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/icholy/sloppy/internal/sloppy"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestSetupStart(t *testing.T) {
+	approve := func(string, *mcp.CreateMessageRequest) bool { return false }
+	tests := []struct {
+		name    string
+		mode    string
+		args    []string
+		approve func(string, *mcp.CreateMessageRequest) bool
+		tools   []string
+		err     string
+	}{
+		{
+			name:  "defaults",
+			mode:  "serve",
+			tools: []string{"builtin-apply_diff", "builtin-read_file", "builtin-run_command", "builtin-write_file"},
+		},
+		{
+			name:  "builtin",
+			mode:  "api",
+			args:  []string{"-builtin=read_file", "-sampling=allow"},
+			tools: []string{"builtin-read_file"},
+		},
+		{
+			name: "ask without approval",
+			mode: "serve",
+			args: []string{"-sampling=ask"},
+			err:  "-sampling=ask is not supported by serve",
+		},
+		{
+			name:    "ask",
+			mode:    "sloppy",
+			args:    []string{"-builtin=false", "-sampling=ask"},
+			approve: approve,
+		},
+		{
+			name: "invalid sampling",
+			mode: "api",
+			args: []string{"-sampling=maybe"},
+			err:  "invalid -sampling value",
+		},
+		{
+			name: "missing config",
+			mode: "api",
+			args: []string{"-config=missing.json"},
+			err:  "missing.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet(tt.mode, flag.ContinueOnError)
+			setup := newSetup(fs, "deny")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			servers := sloppy.NewServerManager()
+			defer servers.Close()
+			_, err := setup.Start(context.Background(), servers, builtinProviders(nil, nil), tt.approve)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var tools []string
+			for _, tool := range servers.Tools() {
+				tools = append(tools, tool.Alias)
+			}
+			slices.Sort(tools)
+			if !slices.Equal(tools, tt.tools) {
+				t.Fatalf("got tools %q, want %q", tools, tt.tools)
+			}
+		})
+	}
+}